@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"slices"
 	"strings"
 	"sync"
 	"syscall"
@@ -58,17 +59,30 @@ func main() {
 		logger.Info("Jaeger 链路追踪已启用")
 	}
 
-	reverseProxy := proxy.NewReverseProxy(logger)
 	requestMetrics := metrics.NewRequestMetrics()
+	upstreamMetrics := metrics.NewUpstreamMetrics()
+	circuitBreakerMetrics := metrics.NewCircuitBreakerMetrics()
+	hedgeMetrics := metrics.NewHedgeMetrics()
+	backendMetrics := metrics.NewBackendMetrics()
+	mirrorMetrics := metrics.NewMirrorMetrics()
+	webSockets := handler.NewWebSockets(metrics.NewWebSocketMetrics(), logger)
+	grpcMetrics := metrics.NewGRPCMetrics()
+	reverseProxy := proxy.NewReverseProxy(upstreamMetrics, logger)
+
+	healthChecks := proxy.NewHealthCheckManager(reverseProxy, upstreamMetrics, logger)
+	defer healthChecks.Stop()
 
 	// 初始化 Consul 服务发现客户端 (如果启用)
 	var serviceDiscovery consul.ServiceDiscovery
+	var discoveryWatcher *proxy.DiscoveryWatcher
 	if cfg.ServiceDiscovery.Enabled && cfg.ServiceDiscovery.Type == "consul" {
 		serviceDiscovery, err = consul.NewConsulServiceDiscovery(cfg.ServiceDiscovery.Consul.Address, logger)
 		if err != nil {
 			logger.Fatal("Consul 服务发现客户端初始化失败", zap.Error(err))
 		}
 		logger.Info("Consul 服务发现已启用", zap.String("address", cfg.ServiceDiscovery.Consul.Address))
+		discoveryWatcher = proxy.NewDiscoveryWatcher(reverseProxy, serviceDiscovery, logger)
+		defer discoveryWatcher.Stop()
 	} else {
 		logger.Info("服务发现未启用 (或配置为非 Consul 类型)")
 	}
@@ -80,37 +94,33 @@ func main() {
 	r.Use(middleware.RecoverMiddleware(logger))
 	r.Use(middleware.RequestLoggerMiddleware(logger))
 	r.Use(metrics.MetricsMiddleware(requestMetrics))
-	r.Use(middleware.RateLimiterMiddleware(func() config.RateLimitConfig { // 动态获取限流配置
-		cfgMutex.RLock()
-		defer cfgMutex.RUnlock()
-		return currentCfg.RateLimit
-	}(), logger))
-	r.Use(middleware.AuthMiddleware(func() config.AuthConfig { // 动态获取认证配置
-		cfgMutex.RLock()
-		defer cfgMutex.RUnlock()
-		return currentCfg.Auth
-	}, logger))
-	r.Use(middleware.OAuth2Middleware(func() config.AuthConfig { // 动态获取 OAuth 2.0 配置
-		cfgMutex.RLock()
-		defer cfgMutex.RUnlock()
-		return currentCfg.Auth
-	}, logger))
-	r.Use(middleware.TracingMiddleware(shutdownTracer)) // 链路追踪中间件
-
-	// 注册路由处理函数 (从配置加载路由规则)
-	loadRoutes(r, reverseProxy, serviceDiscovery, logger)
+	// 认证、限流、跨域、请求头转换与链路追踪按路由配置的策略链执行，不作用于 /metrics
+
+	deps := &routeDeps{
+		reverseProxy:     reverseProxy,
+		serviceDiscovery: serviceDiscovery,
+		discoveryWatcher: discoveryWatcher,
+		healthChecks:     healthChecks,
+		circuitBreakers:  handler.NewCircuitBreakers(circuitBreakerMetrics, logger),
+		hedges:           handler.NewHedges(hedgeMetrics),
+		rateLimiters:     middleware.NewRateLimiters(),
+		backendMetrics:   backendMetrics,
+		shutdownTracer:   shutdownTracer,
+		mirrorMetrics:    mirrorMetrics,
+		webSockets:       webSockets,
+		grpcMetrics:      grpcMetrics,
+		logger:           logger,
+	}
 
-	// 注册 metrics endpoint
+	// 注册 metrics endpoint (先于网关路由匹配)
 	r.HandleFunc("/metrics", metrics.PrometheusHandler())
 
+	// 注册路由处理函数 (从配置加载路由规则)
+	loadRoutes(r, deps)
+
 	// 启动 HTTP 服务器
-	server := &http.Server{
-		Addr:         fmt.Sprintf(":%d", cfg.Port),
-		Handler:      r,
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 10 * time.Second,
-		IdleTimeout:  30 * time.Second,
-	}
+	server := newServer(cfg.Port, r, serverProtocols(cfg))
+	servers := []*http.Server{server}
 
 	go func() {
 		logger.Info("网关服务启动", zap.Int("port", cfg.Port))
@@ -119,8 +129,21 @@ func main() {
 		}
 	}()
 
+	// 启动 HTTPS 服务器与 HTTP 跳转 HTTPS 的服务器 (如果启用)
+	if cfg.TLS.Enabled {
+		tlsServers, certificates, err := setupTLSServers(cfg, r, metrics.NewTLSMetrics(), logger)
+		if err != nil {
+			logger.Fatal("HTTPS 服务初始化失败", zap.Error(err))
+		}
+		defer certificates.Close()
+		for _, tlsServer := range tlsServers {
+			go serveTLS(tlsServer, logger)
+		}
+		servers = append(servers, tlsServers...)
+	}
+
 	// 启动配置动态加载 goroutine
-	go watchConfigChanges("./config/config.yaml", logger, r, reverseProxy, serviceDiscovery)
+	go watchConfigChanges("./config/config.yaml", r, deps)
 
 	// 优雅停机信号处理
 	quit := make(chan os.Signal, 1)
@@ -131,65 +154,111 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	if err := server.Shutdown(ctx); err != nil {
-		logger.Error("网关服务关闭时发生错误", zap.Error(err))
+	var wg sync.WaitGroup
+	for _, srv := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := srv.Shutdown(ctx); err != nil {
+				logger.Error("网关服务关闭时发生错误", zap.String("addr", srv.Addr), zap.Error(err))
+			}
+		}()
+	}
+	wg.Wait()
+	// 已升级的 WebSocket 连接不受 server.Shutdown 管理，发送关闭帧后等待其关闭
+	if err := webSockets.Shutdown(ctx); err != nil {
+		logger.Error("WebSocket 连接关闭时发生错误", zap.Error(err))
 	}
 
 	logger.Info("网关服务已关闭")
 }
 
+// routeDeps 加载路由所依赖的组件
+type routeDeps struct {
+	reverseProxy     *proxy.ReverseProxy
+	serviceDiscovery consul.ServiceDiscovery // 未启用服务发现时为 nil
+	discoveryWatcher *proxy.DiscoveryWatcher // 未启用服务发现时为 nil
+	healthChecks     *proxy.HealthCheckManager
+	circuitBreakers  *handler.CircuitBreakers
+	hedges           *handler.Hedges
+	rateLimiters     *middleware.RateLimiters
+	backendMetrics   *metrics.BackendMetrics
+	mirrorMetrics    *metrics.MirrorMetrics
+	webSockets       *handler.WebSockets
+	grpcMetrics      *metrics.GRPCMetrics
+	shutdownTracer   func(ctx context.Context) error // 未启用链路追踪时为 nil
+	logger           *zap.Logger
+}
+
 // loadRoutes 从配置加载路由规则并注册处理函数
-func loadRoutes(r *router.Router, reverseProxy *proxy.ReverseProxy, serviceDiscovery consul.ServiceDiscovery, logger *zap.Logger) {
+func loadRoutes(r *router.Router, deps *routeDeps) {
+	logger := deps.logger
+
 	cfgMutex.RLock()
 	defer cfgMutex.RUnlock()
-	routes := currentCfg.Routes // 从全局配置获取路由规则
-
-	r.ClearRoutes() // 清空现有路由规则，重新加载
-
-	for _, route := range routes {
-		var targetURL string
-		if route.ServiceName != "" && serviceDiscovery != nil { // 使用服务发现
-			serviceInstances, err := serviceDiscovery.GetServiceInstances(route.ServiceName)
-			if err != nil {
-				logger.Error("获取服务实例失败", zap.String("service_name", route.ServiceName), zap.Error(err))
-				continue // 跳过当前路由
-			}
-			if len(serviceInstances) == 0 {
-				logger.Warn("未找到服务实例", zap.String("service_name", route.ServiceName))
-				continue // 跳过当前路由
-			}
-			//  这里简单选择第一个实例，实际场景中应实现负载均衡策略
-			targetURL = fmt.Sprintf("http://%s:%d", serviceInstances[0].Host, serviceInstances[0].Port)
-			logger.Debug("使用服务发现，路由到服务实例", zap.String("path", route.Path), zap.String("service_name", route.ServiceName), zap.String("target_url", targetURL))
-
-		} else { // 使用静态 TargetURL (如果配置了)
-			targetURL = route.TargetURL
-			logger.Debug("使用静态 TargetURL", zap.String("path", route.Path), zap.String("target_url", targetURL))
+	builder := newRouteBuilder(deps, currentCfg)
+
+	// 新路由表 (顶层路由与各虚拟主机的路由)，检测冲突通过后整体替换现有路由规则；
+	// 构建期间只收集 Upstream 的实例变化，替换成功后才应用，冲突被拒绝时原路由仍转发到原来的实例
+	table := buildRoutes(builder, nil, currentCfg.Routes)
+	routeCount := len(table)
+	vhosts := make([]router.VirtualHost, 0, len(currentCfg.VirtualHosts))
+	for i := range currentCfg.VirtualHosts {
+		vhostConfig := &currentCfg.VirtualHosts[i]
+		routeConfigs := vhostConfig.Routes
+		if vhostConfig.DefaultRoute != nil {
+			routeConfigs = append(slices.Clip(routeConfigs), vhostConfig.DefaultRoute.AsDefault())
 		}
-
-		if targetURL == "" {
-			logger.Warn("路由目标 URL 未配置，跳过路由注册", zap.String("path", route.Path))
-			continue // 跳过当前路由
+		vhost := router.VirtualHost{
+			Name:    vhostConfig.Name,
+			Domains: vhostConfig.Domains,
+			Routes:  buildRoutes(builder, vhostConfig, routeConfigs),
 		}
+		routeCount += len(vhost.Routes)
+		vhosts = append(vhosts, vhost)
+	}
 
-		timeout, err := time.ParseDuration(route.Timeout)
-		if err != nil {
-			logger.Warn("解析路由超时时间失败，使用默认超时时间", zap.String("path", route.Path), zap.Error(err))
-			timeout = 10 * time.Second // 默认超时时间
+	conflicts, err := r.SetVirtualHosts(table, vhosts)
+	for _, c := range conflicts {
+		if !c.Fatal() {
+			logger.Warn("路由匹配存在歧义", zap.String("route", c.Route), zap.String("other", c.Other), zap.String("detail", c.String()))
 		}
-		getProxy, err := reverseProxy.GetProxy(targetURL)
+	}
+	if err != nil {
+		logger.Error("路由表存在冲突，保留原路由规则", zap.Error(err))
+		return
+	}
+	builder.applyUpstreams() // 新路由表已生效，更新其用到的 Upstream 的实例
+	if deps.discoveryWatcher != nil {
+		deps.discoveryWatcher.Sync(builder.watchedSubsets) // 监听路由用到的服务，停止监听不再使用的服务
+	}
+	deps.healthChecks.Sync(builder.healthCheckConfigs)
+	deps.reverseProxy.SyncOutlierDetection(builder.outlierConfigs)
+	deps.reverseProxy.RetainUpstreams(builder.upstreams) // 健康检查停止后再移除不再使用的 Upstream
+	logger.Info("路由规则加载完成，共注册路由", zap.Int("route_count", routeCount), zap.Int("virtual_host_count", len(vhosts)), zap.Uint64("version", r.Version()))
+}
+
+// buildRoutes 构建一组路由，跳过配置有误的路由；vhost 为路由所属的虚拟主机，顶层路由为 nil
+func buildRoutes(builder *routeBuilder, vhost *config.VirtualHostConfig, routeConfigs []config.RouteConfig) []router.Route {
+	routes := make([]router.Route, 0, len(routeConfigs))
+	for _, routeConfig := range routeConfigs {
+		route, err := builder.build(vhost, routeConfig)
 		if err != nil {
-			logger.Error("获取反向代理失败", zap.String("target_url", targetURL), zap.Error(err))
+			fields := []zap.Field{zap.String("route", routeConfig.ID()), zap.Error(err)}
+			if vhost != nil {
+				fields = append(fields, zap.String("virtual_host", vhost.Name))
+			}
+			builder.deps.logger.Error("创建路由失败，跳过路由注册", fields...)
 			continue // 跳过当前路由
 		}
-		r.HandleFunc(route.Path, handler.ProxyHandler(getProxy, targetURL, timeout, logger))
-		logger.Info("注册路由", zap.String("path", route.Path), zap.String("target_url", targetURL), zap.Duration("timeout", timeout))
+		routes = append(routes, route)
 	}
-	logger.Info("路由规则加载完成，共注册路由", zap.Int("route_count", len(routes)))
+	return routes
 }
 
 // watchConfigChanges 监听配置文件变化并热加载配置
-func watchConfigChanges(configPath string, logger *zap.Logger, r *router.Router, reverseProxy *proxy.ReverseProxy, serviceDiscovery consul.ServiceDiscovery) {
+func watchConfigChanges(configPath string, r *router.Router, deps *routeDeps) {
+	logger := deps.logger
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		logger.Fatal("创建文件监听器失败", zap.Error(err))
@@ -207,8 +276,8 @@ func watchConfigChanges(configPath string, logger *zap.Logger, r *router.Router,
 				if event.Op&fsnotify.Write == fsnotify.Write || event.Op&fsnotify.Create == fsnotify.Create || event.Op&fsnotify.Remove == fsnotify.Remove || event.Op&fsnotify.Rename == fsnotify.Rename {
 					logger.Info("配置文件发生变化，重新加载配置", zap.String("file", event.Name))
 					if newCfg, err := config.LoadConfig(configPath); err == nil {
-						updateConfig(newCfg)                                  // 更新全局配置
-						loadRoutes(r, reverseProxy, serviceDiscovery, logger) // 重新加载路由
+						updateConfig(newCfg) // 更新全局配置
+						loadRoutes(r, deps)  // 重新加载路由
 						logger.Info("配置重新加载完成")
 					} else {
 						logger.Error("重新加载配置失败", zap.Error(err))
@@ -232,6 +301,27 @@ func watchConfigChanges(configPath string, logger *zap.Logger, r *router.Router,
 	<-done // 阻塞直到收到退出信号
 }
 
+// newServer 创建监听 port 的 HTTP 服务器
+func newServer(port int, h http.Handler, protocols *http.Protocols) *http.Server {
+	return &http.Server{
+		Addr:         fmt.Sprintf(":%d", port),
+		Handler:      h,
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 10 * time.Second,
+		IdleTimeout:  30 * time.Second,
+		Protocols:    protocols,
+	}
+}
+
+// serverProtocols 返回服务器接受的协议：HTTP/1.1，TLS 上通过 ALPN 协商的 HTTP/2，以及按配置开启的 h2c
+func serverProtocols(cfg *config.Config) *http.Protocols {
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(true)
+	protocols.SetUnencryptedHTTP2(cfg.H2C)
+	return protocols
+}
+
 // updateConfig 更新全局配置
 func updateConfig(cfg *config.Config) {
 	cfgMutex.Lock()
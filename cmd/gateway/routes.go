@@ -0,0 +1,394 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"api-gateway/internal/balancer"
+	"api-gateway/internal/config"
+	"api-gateway/internal/handler"
+	"api-gateway/internal/middleware"
+	"api-gateway/internal/proxy"
+	"api-gateway/internal/router"
+	"api-gateway/internal/transcoder"
+	"go.uber.org/zap"
+)
+
+// routeBuilder 根据一份配置构建路由，并收集路由用到的 Upstream、服务发现、健康检查与异常检测配置；
+// 构建期间不修改正在使用的 Upstream，新路由表生效后再应用收集到的实例
+type routeBuilder struct {
+	routeResources  // 构建成功的路由用到的资源
+	deps            *routeDeps
+	cfg             *config.Config
+	upstreamConfigs map[string]config.UpstreamConfig
+	defaultPolicies config.PolicyConfig
+	pending         routeResources // 正在构建的路由用到的资源，路由构建成功后才合并
+}
+
+// routeResources 路由用到的 Upstream、服务发现、健康检查与异常检测配置
+type routeResources struct {
+	upstreams          map[string]bool                 // 路由用到的 Upstream 名称
+	upstreamInstances  map[string][]*balancer.Instance // Upstream 名称 -> 新路由表生效后使用的实例
+	watchedSubsets     []proxy.ServiceSubset
+	healthCheckConfigs map[string]config.HealthCheckConfig
+	outlierConfigs     map[string]config.OutlierDetectionConfig
+}
+
+// newRouteResources 创建空的 routeResources
+func newRouteResources() routeResources {
+	return routeResources{
+		upstreams:          make(map[string]bool),
+		upstreamInstances:  make(map[string][]*balancer.Instance),
+		healthCheckConfigs: make(map[string]config.HealthCheckConfig),
+		outlierConfigs:     make(map[string]config.OutlierDetectionConfig),
+	}
+}
+
+// merge 合并另一条路由用到的资源
+func (r *routeResources) merge(other routeResources) {
+	maps.Copy(r.upstreams, other.upstreams)
+	maps.Copy(r.upstreamInstances, other.upstreamInstances)
+	r.watchedSubsets = append(r.watchedSubsets, other.watchedSubsets...)
+	maps.Copy(r.healthCheckConfigs, other.healthCheckConfigs)
+	maps.Copy(r.outlierConfigs, other.outlierConfigs)
+}
+
+// newRouteBuilder 创建 routeBuilder
+func newRouteBuilder(deps *routeDeps, cfg *config.Config) *routeBuilder {
+	upstreamConfigs := make(map[string]config.UpstreamConfig, len(cfg.Upstreams))
+	for _, u := range cfg.Upstreams {
+		upstreamConfigs[u.Name] = u
+	}
+	return &routeBuilder{
+		routeResources:  newRouteResources(),
+		deps:            deps,
+		cfg:             cfg,
+		upstreamConfigs: upstreamConfigs,
+		defaultPolicies: cfg.DefaultPolicies(),
+	}
+}
+
+// build 构建一条路由：按路由动作 (重定向、直接响应或转发) 创建处理函数，并套上路由的策略链；
+// vhost 为路由所属的虚拟主机，顶层路由为 nil。路由用到的资源在构建成功后才被收集，失败的路由不影响其他路由
+func (b *routeBuilder) build(vhost *config.VirtualHostConfig, route config.RouteConfig) (router.Route, error) {
+	b.pending = newRouteResources()
+	id, policies := route.ID(), b.defaultPolicies
+	if vhost != nil { // 虚拟主机的路由标识带上虚拟主机名称，熔断、对冲与指标等按路由区分的状态互不影响
+		id = vhost.Name + ":" + id
+		policies = policies.Merge(vhost.Policies)
+	}
+
+	matcher, err := router.NewMatcher(route)
+	if err != nil {
+		return router.Route{}, fmt.Errorf("解析路由匹配条件失败: %w", err)
+	}
+	rewrite, err := handler.NewPathRewriter(route.Rewrite, matcher.CaptureNames())
+	if err != nil {
+		return router.Route{}, fmt.Errorf("解析路径重写配置失败: %w", err)
+	}
+
+	var routeHandler http.HandlerFunc
+	switch {
+	case route.Redirect != nil:
+		routeHandler, err = handler.RedirectHandler(*route.Redirect, rewrite)
+	case route.DirectResponse != nil:
+		routeHandler, err = handler.DirectResponseHandler(*route.DirectResponse)
+	case route.Transcoding != nil:
+		routeHandler, err = b.transcodingHandler(id, route)
+	default:
+		routeHandler, err = b.proxyHandler(id, route, rewrite)
+	}
+	if err != nil {
+		return router.Route{}, err
+	}
+
+	policies = policies.Merge(route.Policies)
+	if route.GRPC.Enabled && route.GRPC.Web {
+		if policies.CORS != nil && policies.CORS.Enabled {
+			cors := middleware.WithGRPCWebHeaders(*policies.CORS)
+			policies.CORS = &cors
+		}
+		routeHandler = handler.GRPCWebPreflightFallback(routeHandler)
+	}
+
+	// 沿用全局默认限流的路由共用一个令牌桶，虚拟主机或路由自行配置的限流按虚拟主机或路由计数
+	rateLimitScope := ""
+	switch {
+	case route.Policies.RateLimit != nil:
+		rateLimitScope = "route:" + id
+	case vhost != nil && vhost.Policies.RateLimit != nil:
+		rateLimitScope = "vhost:" + vhost.Name
+	}
+	rateLimiter := b.deps.rateLimiters.Get(rateLimitScope, policies.RateLimit)
+	policyChain := middleware.PolicyChain(policies, rateLimiter, b.deps.shutdownTracer, b.deps.logger)
+	serve := policyChain(routeHandler).ServeHTTP
+	if route.GRPC.Enabled { // 策略链 (认证、限流等) 返回的错误同样改写为 grpc-status
+		serve = handler.GRPCHandler(id, serve, route.GRPC, b.deps.grpcMetrics)
+	}
+	b.merge(b.pending)
+	return router.Route{
+		ID:       id,
+		Priority: route.Priority,
+		Matcher:  matcher,
+		Handler:  serve,
+	}, nil
+}
+
+// transcodingHandler 创建 gRPC-JSON 转码的处理函数：转码后的 gRPC 请求按路由的后端配置转发，不做路径重写
+func (b *routeBuilder) transcodingHandler(id string, route config.RouteConfig) (http.HandlerFunc, error) {
+	t, err := transcoder.New(*route.Transcoding)
+	if err != nil {
+		return nil, fmt.Errorf("创建 gRPC-JSON 转码失败: %w", err)
+	}
+	proxyHandler, err := b.proxyHandler(id, route, nil)
+	if err != nil {
+		return nil, err
+	}
+	b.deps.logger.Info("启用 gRPC-JSON 转码", zap.String("route", id), zap.String("descriptor_set", route.Transcoding.DescriptorSet), zap.Int("binding_count", t.Bindings()))
+	return handler.TranscodingHandler(t, proxyHandler), nil
+}
+
+// proxyHandler 创建转发请求的处理函数：规则命中的后端优先，其次按权重拆分到 backends，或转发到路由自身的后端
+func (b *routeBuilder) proxyHandler(id string, route config.RouteConfig, rewrite *handler.PathRewriter) (http.HandlerFunc, error) {
+	logger := b.deps.logger
+
+	// 亲和性 Cookie 只作为一致性哈希的键，其他负载均衡策略下签发的 Cookie 不起作用
+	if route.HashPolicy.AffinityCookie.Enabled {
+		switch strings.ToLower(route.LoadBalancer) {
+		case balancer.PolicyRingHash, balancer.PolicyMaglev:
+		default:
+			return nil, fmt.Errorf("affinity_cookie 仅支持 load_balancer 为 %s 或 %s", balancer.PolicyRingHash, balancer.PolicyMaglev)
+		}
+	}
+
+	timeout, err := time.ParseDuration(route.Timeout)
+	if err != nil {
+		logger.Warn("解析路由超时时间失败，使用默认超时时间", zap.String("path", route.Path), zap.Error(err))
+		timeout = 10 * time.Second // 默认超时时间
+	}
+
+	var mirror *handler.Mirror
+	if route.Mirror.Enabled {
+		upstream, err := b.resolveUpstream(route.Mirror.Backend)
+		if err != nil {
+			return nil, fmt.Errorf("解析流量镜像后端失败: %w", err)
+		}
+		lb, _ := balancer.New(balancer.PolicyRoundRobin, nil)
+		mirror = handler.NewMirror(id, b.deps.reverseProxy, upstream, lb, route.Mirror, b.deps.mirrorMetrics, logger)
+		logger.Info("启用流量镜像", zap.String("route", id), zap.String("upstream", upstream.Name()), zap.Float64("percent", route.Mirror.Percent))
+	}
+
+	opts := handler.ProxyOptions{
+		Timeout:         timeout,
+		CircuitBreakers: b.deps.circuitBreakers.Route(id, route.CircuitBreaker),
+		Retry:           route.Retry,
+		AffinityCookie:  route.HashPolicy.AffinityCookie,
+		Hedge:           b.deps.hedges.Route(id, route.Hedge),
+		Rewrite:         rewrite,
+		Mirror:          mirror,
+		WebSocket:       b.deps.webSockets.Route(id, route.WebSocket),
+		GRPC:            route.GRPC.Enabled,
+		StreamTimeout:   route.GRPC.StreamTimeout,
+	}
+
+	// 未配置 backends 时，路由自身的 upstream、service_name 或 target_url 即唯一的默认后端
+	backendConfigs := route.Backends
+	if err := validateBackendWeights(backendConfigs); err != nil {
+		return nil, err
+	}
+	if len(backendConfigs) == 0 {
+		backendConfigs = []config.BackendConfig{{Upstream: route.Upstream, ServiceName: route.ServiceName, TargetURL: route.TargetURL, Weight: 1}}
+	}
+	backends := make([]handler.Backend, 0, len(backendConfigs))
+	for _, backendConfig := range backendConfigs {
+		backend, err := b.newBackend(id, route, opts, backendConfig)
+		if err != nil {
+			return nil, fmt.Errorf("解析路由后端 %s 失败: %w", backendConfig.Name, err)
+		}
+		backends = append(backends, backend)
+	}
+
+	routeHandler := backends[0].Handler
+	if len(route.Backends) > 0 { // 按权重拆分流量
+		var sticky balancer.KeyFunc
+		if route.SplitSticky.Enabled {
+			sticky, err = balancer.NewKeyFunc(route.SplitSticky.Source, route.SplitSticky.Name, b.cfg.TrustedProxies)
+			if err != nil {
+				return nil, fmt.Errorf("解析流量拆分粘性配置失败: %w", err)
+			}
+		}
+		routeHandler = handler.TrafficSplitHandler(id, backends, sticky, b.deps.backendMetrics)
+	}
+
+	if len(route.BackendRules) > 0 { // 按请求头或 Cookie 选择后端，先于默认后端
+		rules := make([]handler.BackendRule, 0, len(route.BackendRules))
+		for i, ruleConfig := range route.BackendRules {
+			conditions, err := router.NewConditions(ruleConfig.Headers, ruleConfig.Cookies, strings.EqualFold(ruleConfig.Match, "any"))
+			if err != nil {
+				return nil, fmt.Errorf("解析第 %d 条后端规则失败: %w", i+1, err)
+			}
+			backend, err := b.newBackend(id, route, opts, ruleConfig.Backend)
+			if err != nil {
+				return nil, fmt.Errorf("解析第 %d 条后端规则失败: %w", i+1, err)
+			}
+			rules = append(rules, handler.BackendRule{Match: conditions.Match, Backend: backend})
+		}
+		routeHandler = handler.BackendRulesHandler(id, rules, routeHandler, b.deps.backendMetrics)
+	}
+	return routeHandler, nil
+}
+
+// validateBackendWeights 校验流量拆分的后端权重：不能为负，且之和大于 0
+func validateBackendWeights(backends []config.BackendConfig) error {
+	if len(backends) == 0 {
+		return nil
+	}
+	total := 0
+	for _, backend := range backends {
+		if backend.Weight < 0 {
+			return fmt.Errorf("后端 %s 的权重不能为负: %d", backend.Name, backend.Weight)
+		}
+		total += backend.Weight
+	}
+	if total == 0 {
+		return fmt.Errorf("backends 的权重之和必须大于 0")
+	}
+	return nil
+}
+
+// newBackend 创建转发到后端的处理函数，并收集后端 Upstream 需要的健康检查与异常检测配置
+func (b *routeBuilder) newBackend(id string, route config.RouteConfig, opts handler.ProxyOptions, backendConfig config.BackendConfig) (handler.Backend, error) {
+	reverseProxy, logger := b.deps.reverseProxy, b.deps.logger
+
+	upstream, err := b.resolveUpstream(backendConfig)
+	if err != nil {
+		return handler.Backend{}, err
+	}
+	hashKey, err := balancer.NewKeyFunc(route.HashPolicy.Source, route.HashPolicy.Name, b.cfg.TrustedProxies)
+	if err != nil {
+		return handler.Backend{}, fmt.Errorf("解析哈希策略失败: %w", err)
+	}
+	lb, err := balancer.New(route.LoadBalancer, hashKey)
+	if err != nil {
+		return handler.Backend{}, fmt.Errorf("创建负载均衡器失败: %w", err)
+	}
+	if route.LocalityRouting.Enabled {
+		lb = reverseProxy.LocalityAware(upstream, lb, b.cfg.Locality, route.LocalityRouting) // 就近路由
+	}
+
+	proxyHandler, err := handler.ProxyHandler(reverseProxy, upstream, lb, opts, logger)
+	if err != nil {
+		return handler.Backend{}, err
+	}
+
+	// 健康检查与异常检测作用于 Upstream，共用同一 Upstream 的路由须使用相同的配置
+	if route.HealthCheck.Enabled {
+		for _, configs := range []map[string]config.HealthCheckConfig{b.healthCheckConfigs, b.pending.healthCheckConfigs} {
+			if existing, ok := configs[upstream.Name()]; ok && !reflect.DeepEqual(existing, route.HealthCheck) {
+				return handler.Backend{}, fmt.Errorf("后端 %s 的 health_check 与其他路由的配置不同", upstream.Name())
+			}
+		}
+	}
+	if route.OutlierDetection.Enabled {
+		for _, configs := range []map[string]config.OutlierDetectionConfig{b.outlierConfigs, b.pending.outlierConfigs} {
+			if existing, ok := configs[upstream.Name()]; ok && existing != route.OutlierDetection {
+				return handler.Backend{}, fmt.Errorf("后端 %s 的 outlier_detection 与其他路由的配置不同", upstream.Name())
+			}
+		}
+	}
+	if route.HealthCheck.Enabled {
+		b.pending.healthCheckConfigs[upstream.Name()] = route.HealthCheck
+	}
+	if route.OutlierDetection.Enabled {
+		b.pending.outlierConfigs[upstream.Name()] = route.OutlierDetection
+	}
+
+	name := backendConfig.Name
+	if name == "" {
+		name = upstream.Name()
+	}
+	logger.Info("注册路由", zap.String("route", id), zap.String("path", route.Path), zap.String("backend", name), zap.Int("weight", backendConfig.Weight), zap.String("upstream", upstream.Name()), zap.String("load_balancer", route.LoadBalancer), zap.Duration("timeout", opts.Timeout))
+	return handler.Backend{
+		Name:    name,
+		Weight:  backendConfig.Weight,
+		Handler: proxyHandler,
+	}, nil
+}
+
+// resolveUpstream 解析后端对应的 Upstream：优先使用静态后端池，其次服务发现，最后静态 TargetURL；
+// 使用服务发现时记录需要监听的服务子集
+func (b *routeBuilder) resolveUpstream(backend config.BackendConfig) (*proxy.Upstream, error) {
+	reverseProxy, serviceDiscovery, logger := b.deps.reverseProxy, b.deps.serviceDiscovery, b.deps.logger
+
+	if backend.Upstream != "" { // 使用静态后端池
+		upstreamConfig, ok := b.upstreamConfigs[backend.Upstream]
+		if !ok {
+			return nil, fmt.Errorf("引用的后端池不存在: %s", backend.Upstream)
+		}
+		upstream, err := b.staticUpstream(upstreamConfig.Name, upstreamConfig.Targets)
+		if err != nil {
+			return nil, fmt.Errorf("解析静态后端池 %s 失败: %w", backend.Upstream, err)
+		}
+		logger.Debug("使用静态后端池", zap.String("upstream", backend.Upstream), zap.Int("target_count", len(upstreamConfig.Targets)))
+		return upstream, nil
+	}
+
+	if backend.ServiceName != "" && serviceDiscovery != nil { // 使用服务发现
+		subset := proxy.ServiceSubset{ServiceName: backend.ServiceName, Tag: backend.Tag, Meta: backend.Meta}
+		//  先同步查询一次实例，之后的实例变化由 discoveryWatcher 推送；暂时没有实例时仍注册路由，实例上线后自动生效
+		upstream := reverseProxy.GetUpstream(subset.UpstreamName())
+		b.pending.upstreams[upstream.Name()] = true
+		instanceCount := len(upstream.Instances())
+		serviceInstances, err := serviceDiscovery.GetServiceInstances(backend.ServiceName)
+		if err != nil {
+			logger.Error("获取服务实例失败", zap.String("service_name", backend.ServiceName), zap.Error(err))
+		} else {
+			instances := subset.Instances(serviceInstances)
+			b.pending.upstreamInstances[upstream.Name()] = instances
+			instanceCount = len(instances)
+			if instanceCount == 0 {
+				logger.Warn("未找到服务实例", zap.String("service_name", backend.ServiceName), zap.String("upstream", upstream.Name()))
+			}
+		}
+		b.pending.watchedSubsets = append(b.pending.watchedSubsets, subset)
+		logger.Debug("使用服务发现", zap.String("service_name", backend.ServiceName), zap.String("upstream", upstream.Name()), zap.Int("instance_count", instanceCount))
+		return upstream, nil
+	}
+
+	// 使用静态 TargetURL (如果配置了)
+	if backend.TargetURL == "" {
+		return nil, fmt.Errorf("路由目标 URL 未配置")
+	}
+	upstream, err := b.staticUpstream(backend.TargetURL, []config.TargetConfig{{URL: backend.TargetURL}})
+	if err != nil {
+		return nil, fmt.Errorf("解析静态 TargetURL %s 失败: %w", backend.TargetURL, err)
+	}
+	logger.Debug("使用静态 TargetURL", zap.String("target_url", backend.TargetURL))
+	return upstream, nil
+}
+
+// staticUpstream 获取静态目标对应的 Upstream，新路由表生效后其实例更新为 targets
+func (b *routeBuilder) staticUpstream(name string, targets []config.TargetConfig) (*proxy.Upstream, error) {
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("静态后端池 %s 没有配置目标", name)
+	}
+	instances, err := proxy.StaticInstances(targets)
+	if err != nil {
+		return nil, err
+	}
+	upstream := b.deps.reverseProxy.GetUpstream(name)
+	b.pending.upstreams[name] = true
+	b.pending.upstreamInstances[name] = instances
+	return upstream, nil
+}
+
+// applyUpstreams 新路由表生效后更新其用到的 Upstream 的实例
+func (b *routeBuilder) applyUpstreams() {
+	for name, instances := range b.upstreamInstances {
+		b.deps.reverseProxy.GetUpstream(name).SetInstances(instances)
+	}
+}
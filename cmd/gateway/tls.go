@@ -0,0 +1,70 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+
+	"api-gateway/internal/config"
+	"api-gateway/internal/handler"
+	"api-gateway/internal/metrics"
+	"api-gateway/internal/tlsconfig"
+	"go.uber.org/zap"
+)
+
+// setupTLSServers 创建 HTTPS 服务器与 (可选的) HTTP 跳转 HTTPS 的服务器，并开始监听证书文件的变化
+func setupTLSServers(cfg *config.Config, h http.Handler, tlsMetrics *metrics.TLSMetrics, logger *zap.Logger) ([]*http.Server, *tlsconfig.CertificateStore, error) {
+	tlsCfg := cfg.TLS
+	httpsPort := tlsCfg.Port
+	if httpsPort == 0 {
+		httpsPort = 443
+	}
+	if httpsPort == cfg.Port {
+		return nil, nil, fmt.Errorf("tls.port 不能与 port 相同: %d", httpsPort)
+	}
+
+	certificates, err := tlsconfig.NewCertificateStore(tlsCfg.Certificates, tlsMetrics, logger)
+	if err != nil {
+		return nil, nil, err
+	}
+	serverTLSConfig, err := tlsconfig.NewServerConfig(tlsCfg, certificates)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := certificates.Watch(); err != nil {
+		return nil, nil, err
+	}
+
+	httpsServer := newServer(httpsPort, h, serverProtocols(cfg))
+	httpsServer.TLSConfig = serverTLSConfig
+	servers := []*http.Server{httpsServer}
+	logger.Info("HTTPS 已启用", zap.Int("port", httpsPort), zap.Int("certificate_count", len(tlsCfg.Certificates)), zap.String("min_version", tls.VersionName(serverTLSConfig.MinVersion)))
+
+	if tlsCfg.HTTPRedirect.Enabled {
+		redirectPort := tlsCfg.HTTPRedirect.Port
+		if redirectPort == 0 {
+			redirectPort = 80
+		}
+		if redirectPort == cfg.Port || redirectPort == httpsPort {
+			certificates.Close()
+			return nil, nil, fmt.Errorf("tls.http_redirect.port 不能与 port 或 tls.port 相同: %d", redirectPort)
+		}
+		servers = append(servers, newServer(redirectPort, handler.HTTPSRedirectHandler(httpsPort), nil))
+	}
+	return servers, certificates, nil
+}
+
+// serveTLS 启动 HTTPS 服务器，或未配置 TLSConfig 的 HTTP 跳转 HTTPS 服务器
+func serveTLS(server *http.Server, logger *zap.Logger) {
+	var err error
+	if server.TLSConfig != nil {
+		logger.Info("HTTPS 服务启动", zap.String("addr", server.Addr))
+		err = server.ListenAndServeTLS("", "") // 证书由 TLSConfig.GetCertificate 按 SNI 选择
+	} else {
+		logger.Info("HTTP 跳转 HTTPS 服务启动", zap.String("addr", server.Addr))
+		err = server.ListenAndServe()
+	}
+	if err != nil && err != http.ErrServerClosed {
+		logger.Fatal("服务启动失败", zap.String("addr", server.Addr), zap.Error(err))
+	}
+}
@@ -0,0 +1,178 @@
+package balancer
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// 负载均衡策略名称
+const (
+	PolicyRoundRobin         = "round_robin"
+	PolicyWeightedRoundRobin = "weighted_round_robin"
+	PolicyLeastRequest       = "least_request"
+	PolicyPowerOfTwo         = "p2c"
+	PolicyRandom             = "random"
+	PolicyRingHash           = "ring_hash"
+	PolicyMaglev             = "maglev"
+)
+
+// 实例元数据中的特殊键
+const (
+	WeightMetaKey = "weight" // 权重
+	BackupMetaKey = "backup" // 值为 "true" 表示备用实例
+)
+
+// Instance 可被负载均衡选择的后端实例
+type Instance struct {
+	ID     string
+	Scheme string
+	Host   string
+	Port   int
+	Weight int
+	Backup bool // 备用实例，仅在所有主实例都不可用时接收流量
+	Meta   map[string]string
+	Path   string // 转发路径前缀 (可选)，来自静态目标 URL 的路径，如 http://host:8080/v2 的 /v2
+
+	outstanding  atomic.Int64 // 正在处理中的请求数
+	unhealthy    atomic.Bool  // 是否被主动健康检查判定为不健康
+	ejectedUntil atomic.Int64 // 被异常检测弹出的截止时间 (UnixNano)，0 表示未弹出
+}
+
+// NewInstance 创建后端实例，权重与是否备用分别从元数据的 weight (缺省为 1)、backup 字段读取
+func NewInstance(id, scheme, host string, port int, meta map[string]string) *Instance {
+	if scheme == "" {
+		scheme = "http"
+	}
+	weight := 1
+	if w, err := strconv.Atoi(meta[WeightMetaKey]); err == nil && w > 0 {
+		weight = w
+	}
+	return &Instance{
+		ID:     id,
+		Scheme: scheme,
+		Host:   host,
+		Port:   port,
+		Weight: weight,
+		Backup: meta[BackupMetaKey] == "true",
+		Meta:   meta,
+	}
+}
+
+// Addr 返回实例的 host:port 地址
+func (i *Instance) Addr() string {
+	return net.JoinHostPort(i.Host, strconv.Itoa(i.Port))
+}
+
+// URL 返回实例的基础 URL
+func (i *Instance) URL() string {
+	return i.Scheme + "://" + i.Addr()
+}
+
+// Acquire 标记实例开始处理一个请求
+func (i *Instance) Acquire() {
+	i.outstanding.Add(1)
+}
+
+// Release 标记实例完成一个请求
+func (i *Instance) Release() {
+	i.outstanding.Add(-1)
+}
+
+// Outstanding 返回实例当前正在处理的请求数
+func (i *Instance) Outstanding() int64 {
+	return i.outstanding.Load()
+}
+
+// SetHealthy 设置实例的健康状态
+func (i *Instance) SetHealthy(healthy bool) {
+	i.unhealthy.Store(!healthy)
+}
+
+// Healthy 返回实例是否健康
+func (i *Instance) Healthy() bool {
+	return !i.unhealthy.Load()
+}
+
+// Eject 将实例弹出负载均衡直到 until
+func (i *Instance) Eject(until time.Time) {
+	i.ejectedUntil.Store(until.UnixNano())
+}
+
+// Reinstate 立即结束实例的弹出状态
+func (i *Instance) Reinstate() {
+	i.ejectedUntil.Store(0)
+}
+
+// Ejected 返回实例当前是否处于弹出状态
+func (i *Instance) Ejected() bool {
+	until := i.ejectedUntil.Load()
+	return until != 0 && time.Now().UnixNano() < until
+}
+
+// Available 返回实例是否可以接收流量 (健康且未被弹出)
+func (i *Instance) Available() bool {
+	return i.Healthy() && !i.Ejected()
+}
+
+// Balancer 负载均衡器接口，每个请求调用一次 Pick 选择后端实例
+type Balancer interface {
+	// Pick 从可用实例中选择一个，instances 为空时返回 nil
+	Pick(r *http.Request, instances []*Instance) *Instance
+}
+
+// ExcludingBalancer 可以在完整的可用实例上跳过部分实例的负载均衡器；
+// 一致性哈希按完整的可用实例构建哈希环 (查找表)，重试与对冲排除实例时不必重新构建
+type ExcludingBalancer interface {
+	// PickExcluding 从 instances 中选择一个不属于 exclude 的实例，没有这样的实例时返回 nil
+	PickExcluding(r *http.Request, instances, exclude []*Instance) *Instance
+}
+
+// PickExcluding 由 lb 从 instances 中选择一个不属于 exclude 的实例
+func PickExcluding(lb Balancer, r *http.Request, instances, exclude []*Instance) *Instance {
+	if len(exclude) == 0 {
+		return lb.Pick(r, instances)
+	}
+	if eb, ok := lb.(ExcludingBalancer); ok {
+		return eb.PickExcluding(r, instances, exclude)
+	}
+	return lb.Pick(r, Exclude(instances, exclude))
+}
+
+// Exclude 返回 instances 中不属于 exclude 的实例
+func Exclude(instances, exclude []*Instance) []*Instance {
+	candidates := make([]*Instance, 0, len(instances))
+	for _, instance := range instances {
+		if !slices.Contains(exclude, instance) {
+			candidates = append(candidates, instance)
+		}
+	}
+	return candidates
+}
+
+// New 根据策略名称创建负载均衡器，策略为空时使用轮询；hashKey 仅用于一致性哈希策略
+func New(policy string, hashKey KeyFunc) (Balancer, error) {
+	switch strings.ToLower(policy) {
+	case "", PolicyRoundRobin:
+		return NewRoundRobin(), nil
+	case PolicyWeightedRoundRobin:
+		return NewWeightedRoundRobin(), nil
+	case PolicyLeastRequest:
+		return NewLeastRequest(), nil
+	case PolicyPowerOfTwo:
+		return NewPowerOfTwo(), nil
+	case PolicyRandom:
+		return NewRandom(), nil
+	case PolicyRingHash:
+		return NewRingHash(hashKey), nil
+	case PolicyMaglev:
+		return NewMaglev(hashKey), nil
+	default:
+		return nil, fmt.Errorf("未知的负载均衡策略: %s", policy)
+	}
+}
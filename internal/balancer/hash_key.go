@@ -0,0 +1,190 @@
+package balancer
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// 哈希键来源
+const (
+	HashSourceClientIP = "client_ip"
+	HashSourceHeader   = "header"
+	HashSourceCookie   = "cookie"
+	HashSourceJWTClaim = "jwt_claim"
+)
+
+// maxHashWeight 一致性哈希使用的实例权重上限，避免元数据中过大的权重使哈希环 (查找表) 的构建代价失控
+const maxHashWeight = 100
+
+// hashWeight 返回实例在一致性哈希中的权重
+func hashWeight(instance *Instance) int {
+	return min(max(instance.Weight, 1), maxHashWeight)
+}
+
+// randomInstance 从 instances 中随机选择一个不属于 exclude 的实例，用于请求没有哈希键的情况
+func randomInstance(instances, exclude []*Instance) *Instance {
+	if len(exclude) > 0 {
+		instances = Exclude(instances, exclude)
+	}
+	if len(instances) == 0 {
+		return nil
+	}
+	return instances[rand.IntN(len(instances))]
+}
+
+// KeyFunc 从请求中提取哈希键，请求中没有对应的键时返回 false
+type KeyFunc func(r *http.Request) (string, bool)
+
+// hashKeyContextKey 请求上下文中显式指定哈希键的 key
+type hashKeyContextKey struct{}
+
+// WithHashKey 在上下文中显式指定哈希键 (例如网关签发的亲和性 Cookie)，优先于 KeyFunc
+func WithHashKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, hashKeyContextKey{}, key)
+}
+
+// NewKeyFunc 根据来源创建哈希键提取函数，name 为请求头、Cookie 或 JWT claim 的名称；
+// trustedProxies 为可信代理的 IP 或 CIDR，来源为 client_ip 时只有来自可信代理的请求才取 X-Forwarded-For 中的地址
+func NewKeyFunc(source, name string, trustedProxies []string) (KeyFunc, error) {
+	switch strings.ToLower(source) {
+	case "", HashSourceClientIP:
+		trusted, err := parseTrustedProxies(trustedProxies)
+		if err != nil {
+			return nil, err
+		}
+		return clientIPKey(trusted), nil
+	case HashSourceHeader:
+		if name == "" {
+			return nil, fmt.Errorf("哈希键来源 header 需要指定 name")
+		}
+		return func(r *http.Request) (string, bool) {
+			v := r.Header.Get(name)
+			return v, v != ""
+		}, nil
+	case HashSourceCookie:
+		if name == "" {
+			return nil, fmt.Errorf("哈希键来源 cookie 需要指定 name")
+		}
+		return func(r *http.Request) (string, bool) {
+			c, err := r.Cookie(name)
+			if err != nil || c.Value == "" {
+				return "", false
+			}
+			return c.Value, true
+		}, nil
+	case HashSourceJWTClaim:
+		if name == "" {
+			return nil, fmt.Errorf("哈希键来源 jwt_claim 需要指定 name")
+		}
+		return func(r *http.Request) (string, bool) {
+			claims, ok := r.Context().Value("claims").(jwt.MapClaims) // 由 JWT 认证中间件写入
+			if !ok {
+				return "", false
+			}
+			v, ok := claims[name]
+			if !ok || v == nil {
+				return "", false
+			}
+			return fmt.Sprint(v), true
+		}, nil
+	default:
+		return nil, fmt.Errorf("未知的哈希键来源: %s", source)
+	}
+}
+
+// parseTrustedProxies 解析可信代理列表，单个 IP 视为只包含该地址的网段
+func parseTrustedProxies(proxies []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(proxies))
+	for _, p := range proxies {
+		if addr, err := netip.ParseAddr(p); err == nil {
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(p)
+		if err != nil {
+			return nil, fmt.Errorf("无效的可信代理地址 %q: %w", p, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// isTrusted 判断地址是否属于可信代理
+func isTrusted(trusted []netip.Prefix, ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIPKey 使用客户端 IP 作为哈希键：默认取连接的对端地址；对端为可信代理时，
+// 从右向左跳过 X-Forwarded-For 中的可信代理，取第一个不可信的地址，避免客户端伪造该请求头选择哈希位置
+func clientIPKey(trusted []netip.Prefix) KeyFunc {
+	return func(r *http.Request) (string, bool) {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		if host == "" {
+			return "", false
+		}
+		if !isTrusted(trusted, host) {
+			return host, true
+		}
+		hops := r.Header.Values("X-Forwarded-For")
+		for i := len(hops) - 1; i >= 0; i-- {
+			ips := strings.Split(hops[i], ",")
+			for j := len(ips) - 1; j >= 0; j-- {
+				ip := strings.TrimSpace(ips[j])
+				if ip == "" {
+					continue
+				}
+				if !isTrusted(trusted, ip) {
+					return ip, true
+				}
+				host = ip
+			}
+		}
+		return host, true // 整条链路都是可信代理时取最左侧的地址
+	}
+}
+
+// requestHashKey 取请求的哈希键：上下文中显式指定的键优先，其次是 KeyFunc
+func requestHashKey(r *http.Request, keyFunc KeyFunc) (string, bool) {
+	if key, ok := r.Context().Value(hashKeyContextKey{}).(string); ok && key != "" {
+		return key, true
+	}
+	if keyFunc == nil {
+		return "", false
+	}
+	return keyFunc(r)
+}
+
+// hash64 计算字符串的 64 位哈希 (FNV-1a 再经 splitmix64 混合，改善相近字符串的分布)
+func hash64(s string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(s))
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
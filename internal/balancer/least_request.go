@@ -0,0 +1,30 @@
+package balancer
+
+import (
+	"math/rand/v2"
+	"net/http"
+)
+
+// LeastRequest 最少未完成请求负载均衡器
+type LeastRequest struct{}
+
+// NewLeastRequest 创建最少请求负载均衡器
+func NewLeastRequest() *LeastRequest {
+	return &LeastRequest{}
+}
+
+// Pick 选择当前未完成请求数最少的实例，从随机位置开始遍历以打散并列情况
+func (b *LeastRequest) Pick(_ *http.Request, instances []*Instance) *Instance {
+	if len(instances) == 0 {
+		return nil
+	}
+	offset := rand.IntN(len(instances))
+	var best *Instance
+	for i := range instances {
+		instance := instances[(offset+i)%len(instances)]
+		if best == nil || instance.Outstanding() < best.Outstanding() {
+			best = instance
+		}
+	}
+	return best
+}
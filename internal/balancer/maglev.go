@@ -0,0 +1,88 @@
+package balancer
+
+import (
+	"net/http"
+	"slices"
+	"sync"
+)
+
+// maglevTableSize Maglev 查找表大小 (质数)
+const maglevTableSize = 65537
+
+// Maglev Maglev 一致性哈希负载均衡器，查找表均匀且实例变化时重新映射的键很少
+type Maglev struct {
+	keyFunc KeyFunc
+	mu      sync.Mutex
+	members []*Instance // 构建当前查找表时的实例列表
+	table   []*Instance
+}
+
+// NewMaglev 创建 Maglev 负载均衡器
+func NewMaglev(keyFunc KeyFunc) *Maglev {
+	return &Maglev{keyFunc: keyFunc}
+}
+
+// Pick 通过查找表选择实例，请求没有哈希键时随机选择
+func (b *Maglev) Pick(r *http.Request, instances []*Instance) *Instance {
+	return b.PickExcluding(r, instances, nil)
+}
+
+// PickExcluding 同 Pick，查找表按 instances 构建，命中 exclude 中的实例时沿查找表向后寻找
+func (b *Maglev) PickExcluding(r *http.Request, instances, exclude []*Instance) *Instance {
+	key, ok := requestHashKey(r, b.keyFunc)
+	if !ok {
+		return randomInstance(instances, exclude)
+	}
+	if len(instances) == 0 {
+		return nil
+	}
+
+	table := b.getTable(instances)
+	slot := hash64(key) % maglevTableSize
+	for n := range uint64(maglevTableSize) {
+		if instance := table[(slot+n)%maglevTableSize]; !slices.Contains(exclude, instance) {
+			return instance
+		}
+	}
+	return nil
+}
+
+// getTable 返回 instances 对应的查找表，实例列表变化时重新构建
+func (b *Maglev) getTable(instances []*Instance) []*Instance {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if slices.Equal(b.members, instances) {
+		return b.table
+	}
+
+	// 每个实例按自己的排列 (offset + j*skip) 依次认领查找表中的空位，权重越大每轮认领的次数越多
+	offsets := make([]uint64, len(instances))
+	skips := make([]uint64, len(instances))
+	next := make([]uint64, len(instances))
+	for i, instance := range instances {
+		offsets[i] = hash64(instance.ID) % maglevTableSize
+		skips[i] = hash64(instance.ID+"#skip")%(maglevTableSize-1) + 1
+	}
+
+	table := make([]*Instance, maglevTableSize)
+	filled := 0
+	for filled < maglevTableSize {
+		for i, instance := range instances {
+			for turn := 0; turn < hashWeight(instance) && filled < maglevTableSize; turn++ {
+				for {
+					slot := (offsets[i] + next[i]*skips[i]) % maglevTableSize
+					next[i]++
+					if table[slot] == nil {
+						table[slot] = instance
+						filled++
+						break
+					}
+				}
+			}
+		}
+	}
+	b.members = slices.Clone(instances)
+	b.table = table
+	return table
+}
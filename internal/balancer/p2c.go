@@ -0,0 +1,34 @@
+package balancer
+
+import (
+	"math/rand/v2"
+	"net/http"
+)
+
+// PowerOfTwo 随机两选一 (power of two choices) 负载均衡器
+type PowerOfTwo struct{}
+
+// NewPowerOfTwo 创建随机两选一负载均衡器
+func NewPowerOfTwo() *PowerOfTwo {
+	return &PowerOfTwo{}
+}
+
+// Pick 随机选出两个不同实例，返回未完成请求数较少的那个
+func (b *PowerOfTwo) Pick(_ *http.Request, instances []*Instance) *Instance {
+	switch len(instances) {
+	case 0:
+		return nil
+	case 1:
+		return instances[0]
+	}
+	i := rand.IntN(len(instances))
+	j := rand.IntN(len(instances) - 1)
+	if j >= i {
+		j++
+	}
+	first, second := instances[i], instances[j]
+	if second.Outstanding() < first.Outstanding() {
+		return second
+	}
+	return first
+}
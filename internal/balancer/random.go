@@ -0,0 +1,22 @@
+package balancer
+
+import (
+	"math/rand/v2"
+	"net/http"
+)
+
+// Random 随机负载均衡器
+type Random struct{}
+
+// NewRandom 创建随机负载均衡器
+func NewRandom() *Random {
+	return &Random{}
+}
+
+// Pick 随机选择一个实例
+func (b *Random) Pick(_ *http.Request, instances []*Instance) *Instance {
+	if len(instances) == 0 {
+		return nil
+	}
+	return instances[rand.IntN(len(instances))]
+}
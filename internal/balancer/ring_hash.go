@@ -0,0 +1,77 @@
+package balancer
+
+import (
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// ringHashVirtualNodes 每单位权重对应的虚拟节点数
+const ringHashVirtualNodes = 100
+
+// RingHash 一致性哈希环负载均衡器，实例变化时只有少量键被重新映射
+type RingHash struct {
+	keyFunc KeyFunc
+	mu      sync.Mutex
+	members []*Instance // 构建当前哈希环时的实例列表
+	ring    []ringEntry
+}
+
+// ringEntry 哈希环上的虚拟节点
+type ringEntry struct {
+	hash     uint64
+	instance *Instance
+}
+
+// NewRingHash 创建一致性哈希环负载均衡器
+func NewRingHash(keyFunc KeyFunc) *RingHash {
+	return &RingHash{keyFunc: keyFunc}
+}
+
+// Pick 选择哈希环上顺时针方向第一个虚拟节点对应的实例，请求没有哈希键时随机选择
+func (b *RingHash) Pick(r *http.Request, instances []*Instance) *Instance {
+	return b.PickExcluding(r, instances, nil)
+}
+
+// PickExcluding 同 Pick，哈希环按 instances 构建，顺时针方向跳过 exclude 中实例的虚拟节点
+func (b *RingHash) PickExcluding(r *http.Request, instances, exclude []*Instance) *Instance {
+	key, ok := requestHashKey(r, b.keyFunc)
+	if !ok {
+		return randomInstance(instances, exclude)
+	}
+	if len(instances) == 0 {
+		return nil
+	}
+
+	ring := b.getRing(instances)
+	h := hash64(key)
+	i := sort.Search(len(ring), func(i int) bool { return ring[i].hash >= h })
+	for n := range len(ring) {
+		if instance := ring[(i+n)%len(ring)].instance; !slices.Contains(exclude, instance) {
+			return instance
+		}
+	}
+	return nil
+}
+
+// getRing 返回 instances 对应的哈希环，实例列表变化时重新构建
+func (b *RingHash) getRing(instances []*Instance) []ringEntry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if slices.Equal(b.members, instances) {
+		return b.ring
+	}
+	ring := make([]ringEntry, 0, len(instances)*ringHashVirtualNodes)
+	for _, instance := range instances {
+		for v := 0; v < hashWeight(instance)*ringHashVirtualNodes; v++ {
+			ring = append(ring, ringEntry{hash: hash64(instance.ID + "#" + strconv.Itoa(v)), instance: instance})
+		}
+	}
+	sort.Slice(ring, func(i, j int) bool { return ring[i].hash < ring[j].hash })
+	b.members = slices.Clone(instances)
+	b.ring = ring
+	return ring
+}
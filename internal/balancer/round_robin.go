@@ -0,0 +1,25 @@
+package balancer
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// RoundRobin 轮询负载均衡器
+type RoundRobin struct {
+	next atomic.Uint64
+}
+
+// NewRoundRobin 创建轮询负载均衡器
+func NewRoundRobin() *RoundRobin {
+	return &RoundRobin{}
+}
+
+// Pick 按顺序依次选择实例
+func (b *RoundRobin) Pick(_ *http.Request, instances []*Instance) *Instance {
+	if len(instances) == 0 {
+		return nil
+	}
+	n := b.next.Add(1) - 1
+	return instances[n%uint64(len(instances))]
+}
@@ -0,0 +1,54 @@
+package balancer
+
+import (
+	"net/http"
+	"sync"
+)
+
+// WeightedRoundRobin 平滑加权轮询负载均衡器 (与 Nginx 的 smooth weighted round-robin 算法一致)
+type WeightedRoundRobin struct {
+	mu      sync.Mutex
+	current map[string]int // 实例 ID -> 当前权重
+}
+
+// NewWeightedRoundRobin 创建加权轮询负载均衡器
+func NewWeightedRoundRobin() *WeightedRoundRobin {
+	return &WeightedRoundRobin{
+		current: make(map[string]int),
+	}
+}
+
+// Pick 按实例权重平滑地选择实例
+func (b *WeightedRoundRobin) Pick(_ *http.Request, instances []*Instance) *Instance {
+	if len(instances) == 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// 实例集合发生变化后清理已不存在的实例状态
+	if len(b.current) > len(instances) {
+		alive := make(map[string]struct{}, len(instances))
+		for _, instance := range instances {
+			alive[instance.ID] = struct{}{}
+		}
+		for id := range b.current {
+			if _, ok := alive[id]; !ok {
+				delete(b.current, id)
+			}
+		}
+	}
+
+	var best *Instance
+	total := 0
+	for _, instance := range instances {
+		b.current[instance.ID] += instance.Weight
+		total += instance.Weight
+		if best == nil || b.current[instance.ID] > b.current[best.ID] {
+			best = instance
+		}
+	}
+	b.current[best.ID] -= total
+	return best
+}
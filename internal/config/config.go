@@ -1,7 +1,9 @@
 package config
 
 import (
+	"math"
 	"os"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v2"
@@ -11,11 +13,27 @@ import (
 type Config struct {
 	Port             int                    `yaml:"port"`
 	LogLevel         string                 `yaml:"log_level"`
-	RateLimit        RateLimitConfig        `yaml:"rate_limit"`
-	Auth             AuthConfig             `yaml:"auth"`
+	H2C              bool                   `yaml:"h2c"`               // 在明文端口上接受 HTTP/2 (prior knowledge)，不使用 TLS 的 gRPC 客户端需要开启
+	TLS              TLSConfig              `yaml:"tls"`               // HTTPS 监听 (可选)，与明文端口同时提供服务
+	RateLimit        RateLimitConfig        `yaml:"rate_limit"`        // 未配置 policies.rate_limit 时作为默认限流策略
+	Auth             AuthConfig             `yaml:"auth"`              // 未配置 policies.auth 时作为默认认证策略
+	Policies         PolicyConfig           `yaml:"policies"`          // 路由策略的全局默认值，路由可覆盖或禁用
 	ServiceDiscovery ServiceDiscoveryConfig `yaml:"service_discovery"` // 服务发现配置
 	Jaeger           JaegerConfig           `yaml:"jaeger"`            // Jaeger 配置
-	Routes           []RouteConfig          `yaml:"routes"`
+	Locality         LocalityConfig         `yaml:"locality"`          // 网关自身所在的可用区/地域
+	TrustedProxies   []string               `yaml:"trusted_proxies"`   // 可信代理的 IP 或 CIDR，只有来自这些地址的请求才取 X-Forwarded-For 中的客户端地址
+	Upstreams        []UpstreamConfig       `yaml:"upstreams"`         // 静态后端池，由路由通过名称引用
+	VirtualHosts     []VirtualHostConfig    `yaml:"virtual_hosts"`     // 虚拟主机，按 Host 使用各自的路由表
+	Routes           []RouteConfig          `yaml:"routes"`            // 顶层路由，未匹配任何虚拟主机的请求使用
+}
+
+// VirtualHostConfig 虚拟主机配置
+type VirtualHostConfig struct {
+	Name         string        `yaml:"name"`
+	Domains      []string      `yaml:"domains"`  // 域名 (不含端口)，支持 "*.example.com" 形式的通配符；精确域名优先，其次最长的通配符
+	Policies     PolicyConfig  `yaml:"policies"` // 虚拟主机的默认策略，覆盖全局默认值，路由可继续覆盖
+	Routes       []RouteConfig `yaml:"routes"`
+	DefaultRoute *RouteConfig  `yaml:"default_route"` // 兜底路由 (可选)，匹配该虚拟主机下其他路由都未匹配的请求，无需配置 path
 }
 
 // RateLimitConfig 限流配置 (与之前版本相同)
@@ -33,6 +51,66 @@ type AuthConfig struct {
 	OAuth2  OAuth2Config  `yaml:"oauth2"` // OAuth 2.0 配置
 }
 
+// PolicyConfig 路由策略，未配置 (nil) 的策略沿用全局默认值，配置了的策略整体覆盖默认值 (enabled: false 表示禁用)
+type PolicyConfig struct {
+	Auth      *AuthConfig      `yaml:"auth"`       // 认证
+	RateLimit *RateLimitConfig `yaml:"rate_limit"` // 限流：沿用全局默认值的路由共用一个令牌桶，虚拟主机或路由自行配置时按虚拟主机或路由计数
+	CORS      *CORSConfig      `yaml:"cors"`       // 跨域
+	Headers   *HeadersConfig   `yaml:"headers"`    // 请求头与响应头转换
+	Tracing   *TracingConfig   `yaml:"tracing"`    // 链路追踪 (需启用 Jaeger)
+}
+
+// Merge 返回以 p 为默认值、被 override 中已配置的策略覆盖后的策略
+func (p PolicyConfig) Merge(override PolicyConfig) PolicyConfig {
+	if override.Auth != nil {
+		p.Auth = override.Auth
+	}
+	if override.RateLimit != nil {
+		p.RateLimit = override.RateLimit
+	}
+	if override.CORS != nil {
+		p.CORS = override.CORS
+	}
+	if override.Headers != nil {
+		p.Headers = override.Headers
+	}
+	if override.Tracing != nil {
+		p.Tracing = override.Tracing
+	}
+	return p
+}
+
+// DefaultPolicies 返回全局默认策略，policies 中未配置的认证与限流沿用顶层的 auth 与 rate_limit
+func (c *Config) DefaultPolicies() PolicyConfig {
+	defaults := PolicyConfig{Auth: &c.Auth, RateLimit: &c.RateLimit}
+	return defaults.Merge(c.Policies)
+}
+
+// CORSConfig 跨域配置
+type CORSConfig struct {
+	Enabled          bool          `yaml:"enabled"`
+	AllowOrigins     []string      `yaml:"allow_origins"` // 允许的来源，"*" 表示任意来源
+	AllowMethods     []string      `yaml:"allow_methods"` // 默认 GET, POST, PUT, PATCH, DELETE, OPTIONS
+	AllowHeaders     []string      `yaml:"allow_headers"` // 为空时允许预检请求中声明的所有请求头
+	ExposeHeaders    []string      `yaml:"expose_headers"`
+	AllowCredentials bool          `yaml:"allow_credentials"`
+	MaxAge           time.Duration `yaml:"max_age"` // 预检结果的缓存时间
+}
+
+// HeadersConfig 请求头与响应头转换配置
+type HeadersConfig struct {
+	Enabled        bool              `yaml:"enabled"`
+	RequestSet     map[string]string `yaml:"request_set"` // 设置转发给后端的请求头
+	RequestRemove  []string          `yaml:"request_remove"`
+	ResponseSet    map[string]string `yaml:"response_set"` // 设置返回给客户端的响应头
+	ResponseRemove []string          `yaml:"response_remove"`
+}
+
+// TracingConfig 链路追踪策略，未配置时在启用 Jaeger 后默认开启
+type TracingConfig struct {
+	Enabled bool `yaml:"enabled"`
+}
+
 // JWTAuthConfig JWT 认证配置 (与之前版本相同)
 type JWTAuthConfig struct {
 	SecretKey string `yaml:"secret_key"`
@@ -58,6 +136,30 @@ type ConsulConfig struct {
 	Address string `yaml:"address"`
 }
 
+// TLSConfig HTTPS 监听配置：按 SNI 从多张证书中选择，证书文件变化时自动重新加载；
+// 端口、TLS 版本与密码套件的修改需要重启生效
+type TLSConfig struct {
+	Enabled      bool                `yaml:"enabled"`
+	Port         int                 `yaml:"port"`          // HTTPS 端口，默认 443
+	Certificates []CertificateConfig `yaml:"certificates"`  // 第一张证书作为客户端未发送 SNI 或没有匹配证书时的默认证书
+	MinVersion   string              `yaml:"min_version"`   // 最低 TLS 版本："1.0"、"1.1"、"1.2" (默认) 或 "1.3"
+	CipherSuites []string            `yaml:"cipher_suites"` // TLS 1.2 及以下允许的密码套件 (Go 标准名称)，为空使用 Go 默认的安全套件；TLS 1.3 的密码套件不可配置
+	HTTPRedirect HTTPRedirectConfig  `yaml:"http_redirect"` // HTTP 跳转 HTTPS 的监听 (可选)
+}
+
+// CertificateConfig 证书与私钥文件 (PEM)
+type CertificateConfig struct {
+	CertFile    string   `yaml:"cert_file"`    // 证书链，叶子证书在前
+	KeyFile     string   `yaml:"key_file"`     // 私钥
+	ServerNames []string `yaml:"server_names"` // 使用该证书的 SNI 域名，支持 "*.example.com" 形式的通配符；为空时使用证书中的 DNS 名称
+}
+
+// HTTPRedirectConfig 将明文 HTTP 请求永久重定向 (308) 到 HTTPS 端口的监听
+type HTTPRedirectConfig struct {
+	Enabled bool `yaml:"enabled"`
+	Port    int  `yaml:"port"` // 监听端口，默认 80，不能与 port 相同
+}
+
 // JaegerConfig Jaeger 配置
 type JaegerConfig struct {
 	Enabled      bool   `yaml:"enabled"`
@@ -65,12 +167,307 @@ type JaegerConfig struct {
 	AgentAddress string `yaml:"agent_address"`
 }
 
+// LocalityConfig 网关所在位置，与实例元数据中的 zone/region 比较
+type LocalityConfig struct {
+	Zone          string `yaml:"zone"`
+	Region        string `yaml:"region"`
+	ZoneMetaKey   string `yaml:"zone_meta_key"`   // 实例元数据中表示可用区的键，默认 "zone"
+	RegionMetaKey string `yaml:"region_meta_key"` // 实例元数据中表示地域的键，默认 "region"
+}
+
 // RouteConfig 路由配置 (与之前版本相比，新增 ServiceName 字段，target_url 变为可选)
 type RouteConfig struct {
-	Path        string `yaml:"path"`
-	TargetURL   string `yaml:"target_url"`   //  静态目标 URL (可选，如果使用服务发现则不需要)
-	ServiceName string `yaml:"service_name"` //  服务发现服务名 (可选，如果使用静态 TargetURL 则不需要)
-	Timeout     string `yaml:"timeout"`
+	Name             string                 `yaml:"name"` //  路由名称 (可选)，用于日志、指标与熔断等按路由区分的状态，默认由匹配条件生成
+	Path             string                 `yaml:"path"`
+	MatchType        string                 `yaml:"match_type"`      //  路径匹配方式: "exact" (默认), "prefix" (按路径段匹配前缀), "regex" (完整匹配，可使用捕获组)
+	Rewrite          RewriteConfig          `yaml:"rewrite"`         //  转发前的路径重写 (可选)，重定向时作用于 Location 的路径
+	Redirect         *RedirectConfig        `yaml:"redirect"`        //  重定向 (可选)，配置后不转发请求
+	DirectResponse   *DirectResponseConfig  `yaml:"direct_response"` //  直接响应 (可选)，配置后不转发请求
+	Priority         int                    `yaml:"priority"`        //  匹配优先级 (可选)，数值越大越先匹配；相同时 exact > regex > prefix，最长前缀优先，匹配条件多的优先
+	Methods          []string               `yaml:"methods"`         //  允许的 HTTP 方法 (可选，为空表示不限制)
+	Hosts            []string               `yaml:"hosts"`           //  匹配的 Host (可选)，支持 "*.example.com" 形式的通配符
+	Headers          []ValueMatchConfig     `yaml:"headers"`         //  请求头匹配条件 (可选，全部满足才匹配)
+	QueryParams      []ValueMatchConfig     `yaml:"query_params"`    //  查询参数匹配条件 (可选，全部满足才匹配)
+	TargetURL        string                 `yaml:"target_url"`      //  静态目标 URL (可选，如果使用服务发现则不需要)，URL 中的路径作为转发路径的前缀
+	ServiceName      string                 `yaml:"service_name"`    //  服务发现服务名 (可选，如果使用静态 TargetURL 则不需要)
+	Upstream         string                 `yaml:"upstream"`        //  引用 upstreams 中的静态后端池名称 (可选，优先于 service_name 与 target_url)
+	Backends         []BackendConfig        `yaml:"backends"`        //  按权重拆分流量的多个后端 (可选，配置后忽略 upstream、service_name 与 target_url)
+	SplitSticky      StickyConfig           `yaml:"split_sticky"`    //  流量拆分的粘性：相同键的请求固定到同一后端
+	BackendRules     []BackendRuleConfig    `yaml:"backend_rules"`   //  按请求头或 Cookie 选择后端的规则 (可选)，按顺序匹配，先于默认后端
+	Timeout          string                 `yaml:"timeout"`
+	LoadBalancer     string                 `yaml:"load_balancer"`     //  负载均衡策略: "round_robin" (默认), "weighted_round_robin", "least_request", "p2c", "random", "ring_hash", "maglev"
+	HashPolicy       HashPolicyConfig       `yaml:"hash_policy"`       //  一致性哈希策略的哈希键来源 (load_balancer 为 ring_hash 或 maglev 时生效)
+	HealthCheck      HealthCheckConfig      `yaml:"health_check"`      //  主动健康检查配置 (作用于路由的 Upstream，共用同一 Upstream 的路由须配置相同)
+	OutlierDetection OutlierDetectionConfig `yaml:"outlier_detection"` //  被动异常检测配置 (作用于路由的 Upstream，共用同一 Upstream 的路由须配置相同)
+	CircuitBreaker   CircuitBreakerConfig   `yaml:"circuit_breaker"`   //  熔断配置 (按路由 + 后端实例地址分别熔断)
+	Retry            RetryConfig            `yaml:"retry"`             //  重试策略
+	Hedge            HedgeConfig            `yaml:"hedge"`             //  对冲请求策略 (仅对 GET、HEAD、OPTIONS 请求生效)
+	LocalityRouting  LocalityRoutingConfig  `yaml:"locality_routing"`  //  就近路由策略
+	Mirror           MirrorConfig           `yaml:"mirror"`            //  流量镜像：将请求副本异步发送到影子后端
+	WebSocket        WebSocketConfig        `yaml:"websocket"`         //  WebSocket 升级：长连接使用空闲与最长存活时间代替请求超时
+	GRPC             GRPCConfig             `yaml:"grpc"`              //  gRPC 路由：按 /package.Service/Method 匹配，使用 HTTP/2 转发
+	Transcoding      *TranscodingConfig     `yaml:"transcoding"`       //  gRPC-JSON 转码 (可选)：将匹配的 REST/JSON 请求转换为 gRPC 请求转发
+	Policies         PolicyConfig           `yaml:"policies"`          //  路由策略 (认证、限流、跨域、请求头转换、链路追踪)，覆盖全局默认值
+}
+
+// AsDefault 返回作为兜底路由的副本：匹配任意路径，优先级低于其他路由
+func (r RouteConfig) AsDefault() RouteConfig {
+	r.Path = "/"
+	r.MatchType = "prefix"
+	r.Priority = math.MinInt32
+	if r.Name == "" {
+		r.Name = "default"
+	}
+	return r
+}
+
+// MatchPath 返回路由匹配的路径与匹配方式：配置了 grpc.service 时由服务名与方法名生成
+func (r RouteConfig) MatchPath() (path, matchType string) {
+	switch {
+	case !r.GRPC.Enabled || r.GRPC.Service == "":
+		return r.Path, r.MatchType
+	case r.GRPC.Method == "":
+		return "/" + r.GRPC.Service, "prefix"
+	default:
+		return "/" + r.GRPC.Service + "/" + r.GRPC.Method, "exact"
+	}
+}
+
+// ID 返回路由的唯一标识：配置了 Name 时使用 Name，否则由全部匹配条件生成 (以空格分隔)，
+// 如 "GET,POST api.example.com prefix:/api header:X-Version=v2"；匹配条件不同的路由标识不同
+func (r RouteConfig) ID() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	var parts []string
+	if len(r.Methods) > 0 {
+		parts = append(parts, strings.Join(r.Methods, ","))
+	}
+	if len(r.Hosts) > 0 {
+		parts = append(parts, strings.Join(r.Hosts, ","))
+	}
+	path, matchType := r.MatchPath()
+	if matchType = strings.ToLower(matchType); matchType != "" && matchType != "exact" {
+		path = matchType + ":" + path
+	}
+	parts = append(parts, path)
+	for _, h := range r.Headers {
+		parts = append(parts, "header:"+h.String())
+	}
+	for _, q := range r.QueryParams {
+		parts = append(parts, "query:"+q.String())
+	}
+	switch {
+	case r.GRPC.Enabled && r.GRPC.Web:
+		parts = append(parts, "grpc-web")
+	case r.GRPC.Enabled:
+		parts = append(parts, "grpc")
+	}
+	return strings.Join(parts, " ")
+}
+
+// BackendConfig 流量拆分的一个后端，upstream、service_name、target_url 三选一 (优先级同路由)
+type BackendConfig struct {
+	Name        string            `yaml:"name"`   // 后端名称，用于指标与日志 (如 stable、canary)
+	Weight      int               `yaml:"weight"` // 流量权重，不能为负，同一路由的后端权重之和须大于 0
+	Upstream    string            `yaml:"upstream"`
+	ServiceName string            `yaml:"service_name"`
+	Tag         string            `yaml:"tag"`  // 只使用带有该标签的服务实例 (可选，配合 service_name)
+	Meta        map[string]string `yaml:"meta"` // 只使用元数据全部匹配的服务实例 (可选，配合 service_name)，如 version: v2
+	TargetURL   string            `yaml:"target_url"`
+}
+
+// BackendRuleConfig 按请求头或 Cookie 选择后端的规则
+type BackendRuleConfig struct {
+	Headers []ValueMatchConfig `yaml:"headers"`
+	Cookies []ValueMatchConfig `yaml:"cookies"`
+	Match   string             `yaml:"match"` // 条件的组合方式: "all" (默认，全部满足), "any" (任一满足)
+	Backend BackendConfig      `yaml:"backend"`
+}
+
+// MirrorConfig 流量镜像配置，影子后端的响应被丢弃，只记录与主请求的状态码与延迟差异
+type MirrorConfig struct {
+	Enabled       bool          `yaml:"enabled"`
+	Backend       BackendConfig `yaml:"backend"`        // 影子后端 (upstream、service_name、target_url 三选一)
+	Percent       float64       `yaml:"percent"`        // 镜像的请求比例 (0-100]，默认 100
+	Timeout       time.Duration `yaml:"timeout"`        // 镜像请求超时，默认 5s
+	MaxConcurrent int           `yaml:"max_concurrent"` // 同时进行的镜像请求上限，超过时不镜像，默认 100
+	MaxBodyBytes  int64         `yaml:"max_body_bytes"` // 可镜像的请求体上限，默认 64KB，超过时不镜像
+}
+
+// WebSocketConfig WebSocket 路由配置，路由的 timeout 只作为握手超时
+type WebSocketConfig struct {
+	Enabled        bool          `yaml:"enabled"`
+	IdleTimeout    time.Duration `yaml:"idle_timeout"`    // 双向都没有数据时关闭连接，默认 5m
+	MaxLifetime    time.Duration `yaml:"max_lifetime"`    // 连接最长存活时间 (可选，为 0 表示不限制)
+	AllowedOrigins []string      `yaml:"allowed_origins"` // 允许握手的 Origin (可选，为空表示不检查)，"*" 表示任意 Origin
+	MaxConnections int           `yaml:"max_connections"` // 路由同时保持的连接上限 (可选，为 0 表示不限制)
+}
+
+// GRPCConfig gRPC 路由配置，路由只匹配 Content-Type 为 application/grpc 的请求
+type GRPCConfig struct {
+	Enabled       bool          `yaml:"enabled"`
+	Service       string        `yaml:"service"`        // 完整服务名 (可选)，如 "shop.v1.OrderService"；配置后忽略 path 与 match_type
+	Method        string        `yaml:"method"`         // 方法名 (可选，配合 service)，为空表示服务的所有方法
+	Web           bool          `yaml:"web"`            // 同时接受浏览器的 gRPC-Web 请求 (含 grpc-web-text) 与 CORS 预检请求，转换为 gRPC 转发
+	StreamTimeout time.Duration `yaml:"stream_timeout"` // 客户端未携带 grpc-timeout 时的最长时长，默认不限制 (流式 RPC 可能长期存在)；携带时取 grpc-timeout 与路由 timeout 中较小者
+}
+
+// TranscodingConfig gRPC-JSON 转码配置，按 google.api.http 注解或显式绑定将 HTTP 方法与路径映射到 RPC (仅支持一元 RPC)
+type TranscodingConfig struct {
+	DescriptorSet            string                     `yaml:"descriptor_set"`              // protoc --include_imports --descriptor_set_out 生成的描述文件
+	Services                 []string                   `yaml:"services"`                    // 转码的服务全名 (可选，为空表示描述文件中的所有服务)
+	Bindings                 []TranscodingBindingConfig `yaml:"bindings"`                    // 显式绑定 (可选)，配置了显式绑定的方法忽略其注解
+	IgnoreUnknownQueryParams bool                       `yaml:"ignore_unknown_query_params"` // 忽略无法对应到请求字段的查询参数，默认返回 400
+	EmitUnpopulated          bool                       `yaml:"emit_unpopulated"`            // 响应 JSON 包含零值字段
+	UseProtoNames            bool                       `yaml:"use_proto_names"`             // 响应 JSON 使用 proto 字段名，默认使用 lowerCamelCase
+}
+
+// TranscodingBindingConfig HTTP 方法与路径到 RPC 的显式绑定，语义与 google.api.http 相同
+type TranscodingBindingConfig struct {
+	RPC          string `yaml:"rpc"`           // 方法全名，如 "shop.v1.OrderService.GetOrder"
+	Method       string `yaml:"method"`        // HTTP 方法，如 GET、POST
+	Path         string `yaml:"path"`          // 路径模板，如 "/v1/orders/{order_id}"
+	Body         string `yaml:"body"`          // 映射到请求体的字段，"*" 表示整个请求消息，为空表示没有请求体
+	ResponseBody string `yaml:"response_body"` // 作为响应体的响应字段 (可选)，为空表示整个响应消息
+}
+
+// StickyConfig 流量拆分的粘性配置，请求中没有粘性键时按权重随机选择
+type StickyConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Source  string `yaml:"source"` // 粘性键来源: client_ip (默认), header, cookie, jwt_claim
+	Name    string `yaml:"name"`   // 请求头、Cookie 或 JWT claim 的名称
+}
+
+// RedirectConfig 重定向配置：配置 location 时按模板生成，否则基于请求 URL 按各选项修改
+type RedirectConfig struct {
+	StatusCode   int    `yaml:"status_code"`   // 301, 302 (默认), 303, 307, 308
+	Location     string `yaml:"location"`      // Location 模板，可使用 {scheme}、{host}、{path}、{query} 与路由 regex 路径的捕获组 {1}、{name}
+	HTTPSUpgrade bool   `yaml:"https_upgrade"` // 重定向到 https，并去掉请求中的端口
+	Host         string `yaml:"host"`          // 替换 Host (可包含端口)
+	StripQuery   bool   `yaml:"strip_query"`   // 去掉查询参数
+}
+
+// DirectResponseConfig 直接响应配置，body 与 body_file 二选一
+type DirectResponseConfig struct {
+	StatusCode int               `yaml:"status_code"` // 默认 200
+	Headers    map[string]string `yaml:"headers"`
+	Body       string            `yaml:"body"`
+	BodyFile   string            `yaml:"body_file"` // 加载配置时读取的响应体文件
+}
+
+// RewriteConfig 路径重写配置，依次执行 strip_prefix、regex 替换、add_prefix；配置 template 时直接按模板生成路径
+type RewriteConfig struct {
+	StripPrefix string `yaml:"strip_prefix"` // 去掉的路径前缀
+	AddPrefix   string `yaml:"add_prefix"`   // 添加的路径前缀
+	Regex       string `yaml:"regex"`        // 对路径做正则替换
+	Replacement string `yaml:"replacement"`  // 替换内容，可使用 $1、${name} 引用 regex 的捕获组
+	Template    string `yaml:"template"`     // 完整路径模板，{1}、{name} 引用路由 regex 路径的捕获组 (须存在)
+}
+
+// ValueMatchConfig 请求头或查询参数的匹配条件：value 与 regex 都为空时只要求存在
+type ValueMatchConfig struct {
+	Name  string `yaml:"name"`
+	Value string `yaml:"value"` // 精确匹配的值
+	Regex string `yaml:"regex"` // 正则匹配
+}
+
+// String 返回匹配条件的描述，如 "X-Version=v2" 或 "X-Version~^v[23]$"
+func (v ValueMatchConfig) String() string {
+	if v.Regex != "" {
+		return v.Name + "~" + v.Regex
+	}
+	return v.Name + "=" + v.Value
+}
+
+// UpstreamConfig 静态后端池配置
+type UpstreamConfig struct {
+	Name    string         `yaml:"name"`
+	Targets []TargetConfig `yaml:"targets"`
+}
+
+// TargetConfig 静态后端池中的目标
+type TargetConfig struct {
+	URL    string            `yaml:"url"`
+	Weight int               `yaml:"weight"` // 权重，默认 1
+	Backup bool              `yaml:"backup"` // 备用目标，仅在所有主目标都不可用时接收流量
+	Meta   map[string]string `yaml:"meta"`   // 目标元数据，与服务发现实例的元数据用法一致
+}
+
+// HashPolicyConfig 一致性哈希 (会话亲和) 配置
+type HashPolicyConfig struct {
+	Source         string               `yaml:"source"`          // 哈希键来源: "client_ip" (默认), "header", "cookie", "jwt_claim"
+	Name           string               `yaml:"name"`            // 请求头、Cookie 或 JWT claim 的名称
+	AffinityCookie AffinityCookieConfig `yaml:"affinity_cookie"` // 网关签发的亲和性 Cookie，启用后优先于 source，仅支持 ring_hash 与 maglev
+}
+
+// AffinityCookieConfig 网关签发的亲和性 Cookie 配置
+type AffinityCookieConfig struct {
+	Enabled bool          `yaml:"enabled"`
+	Name    string        `yaml:"name"` // Cookie 名称，默认 "GW_AFFINITY"
+	Path    string        `yaml:"path"` // Cookie 路径，默认 "/"
+	TTL     time.Duration `yaml:"ttl"`  // 有效期，0 表示会话 Cookie
+}
+
+// HealthCheckConfig 主动健康检查配置
+type HealthCheckConfig struct {
+	Enabled            bool          `yaml:"enabled"`
+	Path               string        `yaml:"path"`                // 健康检查路径，例如 "/health"
+	ExpectedStatuses   []int         `yaml:"expected_statuses"`   // 视为健康的状态码，默认 [200]
+	Interval           time.Duration `yaml:"interval"`            // 检查间隔，默认 10s
+	Timeout            time.Duration `yaml:"timeout"`             // 单次检查超时，默认 2s
+	HealthyThreshold   int           `yaml:"healthy_threshold"`   // 连续成功多少次后恢复，默认 2
+	UnhealthyThreshold int           `yaml:"unhealthy_threshold"` // 连续失败多少次后摘除，默认 3
+}
+
+// OutlierDetectionConfig 被动异常检测配置，根据真实流量的结果弹出异常实例
+type OutlierDetectionConfig struct {
+	Enabled            bool          `yaml:"enabled"`
+	ConsecutiveErrors  int           `yaml:"consecutive_errors"`   // 连续失败 (5xx 或连接错误) 多少次后弹出，默认 5
+	ErrorRateThreshold float64       `yaml:"error_rate_threshold"` // 统计窗口内错误率达到该值 (0~1) 时弹出，0 表示不按错误率弹出
+	MinRequests        int           `yaml:"min_requests"`         // 按错误率判定所需的窗口内最少请求数，默认 20
+	Interval           time.Duration `yaml:"interval"`             // 错误率统计窗口，默认 10s
+	BaseEjectionTime   time.Duration `yaml:"base_ejection_time"`   // 基础弹出时长，实际时长为 基础时长 x 累计弹出次数，默认 30s
+	MaxEjectionTime    time.Duration `yaml:"max_ejection_time"`    // 最长弹出时长，默认 5m
+	MaxEjectionPercent int           `yaml:"max_ejection_percent"` // 同一 Upstream 最多可弹出的实例百分比，默认 50
+}
+
+// CircuitBreakerConfig 熔断器配置
+type CircuitBreakerConfig struct {
+	Enabled          bool          `yaml:"enabled"`
+	FailureThreshold int           `yaml:"failure_threshold"`  // 连续失败 (5xx 或连接错误) 多少次后熔断，默认 5
+	OpenTimeout      time.Duration `yaml:"open_timeout"`       // 熔断多久后进入半开状态，默认 30s
+	HalfOpenRequests int           `yaml:"half_open_requests"` // 半开状态允许的探测请求数，默认 1
+	SuccessThreshold int           `yaml:"success_threshold"`  // 半开状态连续成功多少次后恢复，默认等于 half_open_requests
+	StatusCode       int           `yaml:"status_code"`        // 熔断时返回的状态码，默认 503
+	Body             string        `yaml:"body"`               // 熔断时返回的响应内容
+}
+
+// RetryConfig 重试策略配置
+type RetryConfig struct {
+	Enabled             bool          `yaml:"enabled"`
+	Attempts            int           `yaml:"attempts"`               // 总尝试次数 (含首次请求)，默认 3
+	RetryOn             []string      `yaml:"retry_on"`               // 可重试条件: "connect_failure", "reset", "502", "503", "504"，默认 connect_failure 与 503
+	RetryNonIdempotent  bool          `yaml:"retry_non_idempotent"`   // 是否允许重试 POST、PATCH 等非幂等请求 (连接失败总是可以重试)
+	BackoffBase         time.Duration `yaml:"backoff_base"`           // 指数退避基础间隔，默认 25ms
+	BackoffMax          time.Duration `yaml:"backoff_max"`            // 指数退避最大间隔，默认 250ms
+	BudgetRatio         float64       `yaml:"budget_ratio"`           // 重试预算：统计窗口内重试数占请求数的最大比例，默认 0.2
+	MinRetriesPerSecond int           `yaml:"min_retries_per_second"` // 重试预算：请求量很低时每秒至少允许的重试次数，默认 10
+	MaxBodyBytes        int64         `yaml:"max_body_bytes"`         // 为重试缓冲的最大请求体字节数，超过则该请求不重试，默认 64KB
+}
+
+// HedgeConfig 对冲请求配置：首个请求在延迟内未响应时向另一个实例再发一次，使用先返回的响应
+type HedgeConfig struct {
+	Enabled       bool          `yaml:"enabled"`
+	Delay         time.Duration `yaml:"delay"`           // 固定对冲延迟，默认 100ms；配置 percentile 时作为样本不足时的兜底值
+	Percentile    float64       `yaml:"percentile"`      // 以最近响应延迟的该百分位 (0~1，例如 0.95) 作为对冲延迟，0 表示使用固定延迟
+	MaxHedgeRatio float64       `yaml:"max_hedge_ratio"` // 对冲请求数占请求数的最大比例，默认 0.1
+}
+
+// LocalityRoutingConfig 就近路由配置：优先使用同可用区的实例，本地健康容量不足时溢出到同地域、再到其他地域
+type LocalityRoutingConfig struct {
+	Enabled           bool `yaml:"enabled"`
+	MinHealthyPercent int  `yaml:"min_healthy_percent"` // 本地可用容量 (按权重) 低于该百分比时溢出，默认 70
 }
 
 // LoadConfig 从 YAML 文件加载配置 (与之前版本相同)
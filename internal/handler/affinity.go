@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"api-gateway/internal/balancer"
+	"api-gateway/internal/config"
+)
+
+// defaultAffinityCookieName 亲和性 Cookie 的默认名称
+const defaultAffinityCookieName = "GW_AFFINITY"
+
+// applyAffinityCookie 读取网关签发的亲和性 Cookie 作为一致性哈希的键；请求中没有时签发新的 Cookie
+func applyAffinityCookie(w http.ResponseWriter, r *http.Request, cfg config.AffinityCookieConfig) *http.Request {
+	name := cfg.Name
+	if name == "" {
+		name = defaultAffinityCookieName
+	}
+	if c, err := r.Cookie(name); err == nil && c.Value != "" {
+		return r.WithContext(balancer.WithHashKey(r.Context(), c.Value))
+	}
+
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	value := hex.EncodeToString(buf)
+
+	path := cfg.Path
+	if path == "" {
+		path = "/"
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		MaxAge:   int(cfg.TTL.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return r.WithContext(balancer.WithHashKey(r.Context(), value))
+}
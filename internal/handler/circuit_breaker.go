@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"sync"
+
+	"api-gateway/internal/config"
+	"api-gateway/internal/metrics"
+	"api-gateway/internal/proxy"
+	"api-gateway/pkg/circuitbreaker"
+	"go.uber.org/zap"
+)
+
+// errCircuitOpen 路由的所有可用实例均已熔断
+var errCircuitOpen = errors.New("熔断器已打开")
+
+// CircuitBreakers 管理所有路由的熔断器，配置不变时在配置热加载后保留熔断状态
+type CircuitBreakers struct {
+	routes  map[string]*RouteBreakers // 路由 -> 该路由的熔断器
+	mu      sync.Mutex
+	metrics *metrics.CircuitBreakerMetrics
+	logger  *zap.Logger
+}
+
+// NewCircuitBreakers 创建 CircuitBreakers
+func NewCircuitBreakers(breakerMetrics *metrics.CircuitBreakerMetrics, logger *zap.Logger) *CircuitBreakers {
+	return &CircuitBreakers{
+		routes:  make(map[string]*RouteBreakers),
+		metrics: breakerMetrics,
+		logger:  logger,
+	}
+}
+
+// Route 获取路由的熔断器组，未启用熔断时返回 nil；配置变化时重新创建
+func (cbs *CircuitBreakers) Route(route string, cfg config.CircuitBreakerConfig) *RouteBreakers {
+	cbs.mu.Lock()
+	defer cbs.mu.Unlock()
+
+	if !cfg.Enabled {
+		delete(cbs.routes, route)
+		return nil
+	}
+	if rb, ok := cbs.routes[route]; ok && reflect.DeepEqual(rb.cfg, cfg) {
+		return rb
+	}
+	rb := &RouteBreakers{
+		route:    route,
+		cfg:      cfg,
+		breakers: make(map[string]*circuitbreaker.CircuitBreaker),
+		metrics:  cbs.metrics,
+		logger:   cbs.logger,
+	}
+	cbs.routes[route] = rb
+	return rb
+}
+
+// RouteBreakers 单个路由下按后端实例地址划分的熔断器
+type RouteBreakers struct {
+	route    string
+	cfg      config.CircuitBreakerConfig
+	breakers map[string]*circuitbreaker.CircuitBreaker // 实例地址 -> 熔断器
+	mu       sync.Mutex
+	metrics  *metrics.CircuitBreakerMetrics
+	logger   *zap.Logger
+}
+
+// get 获取或创建指定实例地址的熔断器
+func (rb *RouteBreakers) get(host string) *circuitbreaker.CircuitBreaker {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	if cb, ok := rb.breakers[host]; ok {
+		return cb
+	}
+	cb := circuitbreaker.NewCircuitBreaker(circuitbreaker.Settings{
+		FailureThreshold:    rb.cfg.FailureThreshold,
+		OpenTimeout:         rb.cfg.OpenTimeout,
+		HalfOpenMaxRequests: rb.cfg.HalfOpenRequests,
+		SuccessThreshold:    rb.cfg.SuccessThreshold,
+		OnStateChange: func(from, to circuitbreaker.State) {
+			rb.metrics.ObserveTransition(rb.route, host, from.String(), to.String(), int(to))
+			fields := []zap.Field{zap.String("route", rb.route), zap.String("host", host), zap.String("from", from.String()), zap.String("to", to.String())}
+			if to == circuitbreaker.StateOpen {
+				rb.logger.Warn("熔断器打开", fields...)
+			} else {
+				rb.logger.Info("熔断器状态变化", fields...)
+			}
+		},
+	})
+	rb.breakers[host] = cb
+	rb.metrics.SetState(rb.route, host, int(circuitbreaker.StateClosed))
+	return cb
+}
+
+// allow 判断是否放行发往 host 的请求
+func (rb *RouteBreakers) allow(host string) (*circuitbreaker.CircuitBreaker, bool) {
+	cb := rb.get(host)
+	if !cb.Allow() {
+		rb.metrics.ObserveRejected(rb.route, host)
+		return cb, false
+	}
+	return cb, true
+}
+
+// openError 返回熔断时的快速失败错误
+func (rb *RouteBreakers) openError() error {
+	statusCode := rb.cfg.StatusCode
+	if statusCode == 0 {
+		statusCode = http.StatusServiceUnavailable
+	}
+	body := rb.cfg.Body
+	if body == "" {
+		body = "服务暂时不可用，请稍后重试"
+	}
+	return &proxy.FailFastError{StatusCode: statusCode, Body: body, Err: errCircuitOpen}
+}
+
+// record 根据请求结果更新熔断器
+func record(cb *circuitbreaker.CircuitBreaker, req *http.Request, resp *http.Response, err error) {
+	switch {
+	case err != nil && proxy.ClientCanceled(req.Context()):
+		cb.Cancel() // 客户端取消，不计入熔断统计；路由超时计为失败
+	case err != nil:
+		cb.Record(false)
+	default:
+		cb.Record(resp.StatusCode < http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+
+	"api-gateway/internal/config"
+)
+
+// DirectResponseHandler 创建直接返回固定响应的处理函数，body_file 在创建时读取
+func DirectResponseHandler(cfg config.DirectResponseConfig) (http.HandlerFunc, error) {
+	status := cfg.StatusCode
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if status < 200 || status > 599 {
+		return nil, fmt.Errorf("无效的响应状态码: %d", cfg.StatusCode)
+	}
+	if cfg.Body != "" && cfg.BodyFile != "" {
+		return nil, fmt.Errorf("body 与 body_file 不能同时配置")
+	}
+
+	body := []byte(cfg.Body)
+	if cfg.BodyFile != "" {
+		var err error
+		if body, err = os.ReadFile(cfg.BodyFile); err != nil {
+			return nil, fmt.Errorf("读取响应体文件失败: %w", err)
+		}
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		for name, value := range cfg.Headers {
+			h.Set(name, value)
+		}
+		if len(body) > 0 && h.Get("Content-Type") == "" {
+			h.Set("Content-Type", http.DetectContentType(body))
+		}
+		h.Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(status)
+		if r.Method != http.MethodHead {
+			_, _ = w.Write(body)
+		}
+	}, nil
+}
@@ -0,0 +1,169 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"api-gateway/internal/config"
+	"api-gateway/internal/metrics"
+	"api-gateway/internal/proxy"
+	"api-gateway/pkg/grpcstatus"
+)
+
+// maxGRPCErrorMessage 作为 grpc-message 的错误响应体上限
+const maxGRPCErrorMessage = 1 << 10
+
+// GRPCHandler 包装 gRPC 路由：cfg.Web 为 true 时同时接受 gRPC-Web 请求并转换为 gRPC；
+// 网关与非 gRPC 后端返回的 HTTP 错误改写为 grpc-status，并按服务与方法记录指标
+func GRPCHandler(routeID string, next http.HandlerFunc, cfg config.GRPCConfig, grpcMetrics *metrics.GRPCMetrics) http.HandlerFunc {
+	service, method := grpcMetricLabels(cfg)
+	return func(w http.ResponseWriter, r *http.Request) {
+		var webWriter *grpcWebResponseWriter
+		switch {
+		case cfg.Web && isGRPCWebRequest(r):
+			webWriter = &grpcWebResponseWriter{ResponseWriter: w, text: isGRPCWebText(r)}
+			w = webWriter
+		case proxy.IsGRPCRequest(r):
+		case cfg.Web && r.Method == http.MethodOptions: // CORS 预检由路由的跨域策略响应
+			next(w, r)
+			return
+		default:
+			http.Error(w, "仅支持 gRPC 与 gRPC-Web 请求", http.StatusUnsupportedMediaType)
+			return
+		}
+
+		start := time.Now()
+		// 流式 RPC 可能超过 http.Server 的读写超时，改由 grpc-timeout 与 grpc.stream_timeout 控制
+		rc := http.NewResponseController(w)
+		_ = rc.SetReadDeadline(time.Time{})
+		_ = rc.SetWriteDeadline(time.Time{})
+
+		gw := &grpcResponseWriter{ResponseWriter: w}
+		if webWriter == nil {
+			next(gw, r)
+		} else if outreq, err := translateGRPCWebRequest(r); err != nil {
+			grpcstatus.WriteError(gw, grpcstatus.InvalidArgument, err.Error())
+		} else {
+			next(gw, outreq)
+		}
+		code := gw.finish()
+		if webWriter != nil {
+			webWriter.finish()
+		}
+
+		grpcMetrics.Observe(routeID, service, method, code.String(), time.Since(start))
+	}
+}
+
+// grpcTimeoutUnits grpc-timeout 请求头的时间单位
+var grpcTimeoutUnits = map[byte]time.Duration{
+	'H': time.Hour,
+	'M': time.Minute,
+	'S': time.Second,
+	'm': time.Millisecond,
+	'u': time.Microsecond,
+	'n': time.Nanosecond,
+}
+
+// grpcTimeout 解析 grpc-timeout 请求头：不超过 8 位的正整数加时间单位，如 "100m"、"5S"
+func grpcTimeout(r *http.Request) (time.Duration, bool) {
+	value := r.Header.Get("Grpc-Timeout")
+	if len(value) < 2 || len(value) > 9 {
+		return 0, false
+	}
+	unit, ok := grpcTimeoutUnits[value[len(value)-1]]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(value[:len(value)-1], 10, 32)
+	if err != nil || n == 0 {
+		return 0, false
+	}
+	return time.Duration(n) * unit, true
+}
+
+// grpcMetricLabels 返回指标的服务与方法标签：只使用路由配置的服务与方法，未配置的记为 unknown，
+// 避免客户端通过任意请求路径产生无限多的标签值
+func grpcMetricLabels(cfg config.GRPCConfig) (service, method string) {
+	service, method = cfg.Service, cfg.Method
+	if service == "" {
+		service = "unknown"
+	}
+	if method == "" {
+		method = "unknown"
+	}
+	return service, method
+}
+
+// grpcResponseWriter 拦截非 200 的响应，在处理结束后改写为 Trailers-Only 的 gRPC 错误响应
+type grpcResponseWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+	errorStatus int             // 被拦截的 HTTP 状态码
+	message     strings.Builder // 被拦截的响应体，作为 grpc-message
+}
+
+func (gw *grpcResponseWriter) WriteHeader(code int) {
+	if gw.wroteHeader {
+		return
+	}
+	if code < http.StatusOK { // 1xx 信息响应直接写出
+		gw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	gw.wroteHeader = true
+	if code != http.StatusOK {
+		gw.errorStatus = code
+		return
+	}
+	gw.ResponseWriter.WriteHeader(code)
+}
+
+func (gw *grpcResponseWriter) Write(b []byte) (int, error) {
+	if !gw.wroteHeader {
+		gw.WriteHeader(http.StatusOK)
+	}
+	if gw.errorStatus != 0 {
+		if n := maxGRPCErrorMessage - gw.message.Len(); n > 0 {
+			gw.message.Write(b[:min(n, len(b))])
+		}
+		return len(b), nil
+	}
+	return gw.ResponseWriter.Write(b)
+}
+
+// Flush 透传流式响应的刷新
+func (gw *grpcResponseWriter) Flush() {
+	if gw.errorStatus == 0 {
+		_ = http.NewResponseController(gw.ResponseWriter).Flush()
+	}
+}
+
+// Unwrap 返回被包装的 ResponseWriter，供 http.ResponseController 使用
+func (gw *grpcResponseWriter) Unwrap() http.ResponseWriter {
+	return gw.ResponseWriter
+}
+
+// finish 写出被拦截的错误，返回响应的 gRPC 状态码
+func (gw *grpcResponseWriter) finish() grpcstatus.Code {
+	if gw.errorStatus != 0 {
+		h := gw.Header()
+		h.Del("Trailer")
+		h.Del("X-Content-Type-Options")
+		code := grpcstatus.FromHTTPStatus(gw.errorStatus)
+		grpcstatus.WriteError(gw.ResponseWriter, code, strings.TrimSpace(gw.message.String()))
+		return code
+	}
+	// 状态码在 Trailers-Only 响应的响应头中，或在响应结束后的 Trailer 中
+	value := gw.Header().Get("Grpc-Status")
+	if value == "" {
+		value = gw.Header().Get(http.TrailerPrefix + "Grpc-Status")
+	}
+	code, err := grpcstatus.Parse(value)
+	if err != nil {
+		return grpcstatus.Unknown
+	}
+	return code
+}
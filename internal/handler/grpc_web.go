@@ -0,0 +1,213 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"api-gateway/internal/proxy"
+	"api-gateway/pkg/grpcstatus"
+)
+
+// gRPC-Web 的 Content-Type 前缀
+const (
+	grpcContentType        = "application/grpc"
+	grpcWebContentType     = "application/grpc-web"
+	grpcWebTextContentType = "application/grpc-web-text"
+)
+
+// maxGRPCWebTextBody grpc-web-text 请求体 (base64) 的上限，与 gRPC 默认的最大消息大小一致
+const maxGRPCWebTextBody = 4 << 20
+
+// grpcWebTrailerFlag gRPC-Web 响应体中 Trailer 帧的标志位
+const grpcWebTrailerFlag = 0x80
+
+// isGRPCWebRequest 判断请求是否为 gRPC-Web 请求 (application/grpc-web 或 application/grpc-web-text，可带 +proto 等后缀)
+func isGRPCWebRequest(r *http.Request) bool {
+	return grpcSubtype(r.Header.Get("Content-Type"), grpcWebContentType) || isGRPCWebText(r)
+}
+
+// isGRPCWebText 判断请求是否为 base64 编码的 gRPC-Web 请求
+func isGRPCWebText(r *http.Request) bool {
+	return grpcSubtype(r.Header.Get("Content-Type"), grpcWebTextContentType)
+}
+
+// grpcSubtype 判断 contentType 是否为 base 或 base 加 +xxx、;xxx 后缀
+func grpcSubtype(contentType, base string) bool {
+	rest, ok := strings.CutPrefix(contentType, base)
+	return ok && (rest == "" || rest[0] == '+' || rest[0] == ';')
+}
+
+// translateGRPCWebRequest 将 gRPC-Web 请求转换为 gRPC 请求：替换 Content-Type，grpc-web-text 请求体解码 base64
+func translateGRPCWebRequest(r *http.Request) (*http.Request, error) {
+	contentType := r.Header.Get("Content-Type")
+	text := isGRPCWebText(r)
+	base := grpcWebContentType
+	if text {
+		base = grpcWebTextContentType
+	}
+
+	outreq := r.Clone(r.Context())
+	outreq.Header.Set("Content-Type", grpcContentType+strings.TrimPrefix(contentType, base))
+	outreq.Header.Set("Te", "trailers")
+	outreq.Header.Del("X-Grpc-Web")
+	outreq.Header.Del("Content-Length")
+	if !text {
+		return outreq, nil
+	}
+
+	encoded, err := io.ReadAll(io.LimitReader(r.Body, maxGRPCWebTextBody+1))
+	if err != nil {
+		return nil, fmt.Errorf("读取请求体失败: %w", err)
+	}
+	if len(encoded) > maxGRPCWebTextBody {
+		return nil, errors.New("请求体超过上限")
+	}
+	body, err := decodeGRPCWebText(encoded)
+	if err != nil {
+		return nil, err
+	}
+	outreq.Body = io.NopCloser(bytes.NewReader(body))
+	outreq.ContentLength = int64(len(body))
+	outreq.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+	return outreq, nil
+}
+
+// decodeGRPCWebText 解码 base64 请求体；客户端可能分段编码，填充符 "=" 会出现在中间，因此按 4 字符为单位解码
+func decodeGRPCWebText(encoded []byte) ([]byte, error) {
+	encoded = bytes.Join(bytes.Fields(encoded), nil)
+	if len(encoded)%4 != 0 {
+		return nil, errors.New("grpc-web-text 请求体不是有效的 base64")
+	}
+	body := make([]byte, 0, base64.StdEncoding.DecodedLen(len(encoded)))
+	buf := make([]byte, 3)
+	for i := 0; i < len(encoded); i += 4 {
+		n, err := base64.StdEncoding.Decode(buf, encoded[i:i+4])
+		if err != nil {
+			return nil, fmt.Errorf("grpc-web-text 请求体不是有效的 base64: %w", err)
+		}
+		body = append(body, buf[:n]...)
+	}
+	return body, nil
+}
+
+// grpcWebResponseWriter 将 gRPC 响应转换为 gRPC-Web 响应：替换 Content-Type，Trailer 编码为响应体末尾的 Trailer 帧，
+// grpc-web-text 时响应体按 base64 编码
+type grpcWebResponseWriter struct {
+	http.ResponseWriter
+	text        bool
+	wroteHeader bool
+	wroteBody   bool
+	trailers    []string // 后端声明的 Trailer
+}
+
+func (ww *grpcWebResponseWriter) WriteHeader(code int) {
+	if ww.wroteHeader {
+		return
+	}
+	if code < http.StatusOK {
+		ww.ResponseWriter.WriteHeader(code)
+		return
+	}
+	ww.wroteHeader = true
+
+	h := ww.Header()
+	if contentType := h.Get("Content-Type"); grpcSubtype(contentType, grpcContentType) {
+		base := grpcWebContentType
+		if ww.text {
+			base = grpcWebTextContentType
+		}
+		h.Set("Content-Type", base+strings.TrimPrefix(contentType, grpcContentType))
+	}
+	h.Del("Content-Length")
+	// 浏览器无法读取 HTTP Trailer，声明的 Trailer 改为写入响应体
+	for _, value := range h.Values("Trailer") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				ww.trailers = append(ww.trailers, name)
+			}
+		}
+	}
+	h.Del("Trailer")
+	ww.ResponseWriter.WriteHeader(code)
+}
+
+func (ww *grpcWebResponseWriter) Write(b []byte) (int, error) {
+	if !ww.wroteHeader {
+		ww.WriteHeader(http.StatusOK)
+	}
+	if len(b) == 0 {
+		return 0, nil
+	}
+	ww.wroteBody = true
+	if !ww.text {
+		return ww.ResponseWriter.Write(b)
+	}
+	// 每段单独编码 (可能带填充符)，gRPC-Web 客户端按 4 字符为单位解码
+	if _, err := ww.ResponseWriter.Write([]byte(base64.StdEncoding.EncodeToString(b))); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// Flush 透传流式响应的刷新
+func (ww *grpcWebResponseWriter) Flush() {
+	_ = http.NewResponseController(ww.ResponseWriter).Flush()
+}
+
+// Unwrap 返回被包装的 ResponseWriter，供 http.ResponseController 使用
+func (ww *grpcWebResponseWriter) Unwrap() http.ResponseWriter {
+	return ww.ResponseWriter
+}
+
+// finish 将后端返回的 Trailer 编码为 Trailer 帧写入响应体；Trailers-Only 响应的状态已在响应头中，不再重复
+func (ww *grpcWebResponseWriter) finish() {
+	if !ww.wroteHeader {
+		return
+	}
+	h := ww.Header()
+	var trailer bytes.Buffer
+	writeField := func(name string, values []string) {
+		for _, value := range values {
+			fmt.Fprintf(&trailer, "%s: %s\r\n", strings.ToLower(name), value)
+		}
+	}
+	for _, name := range ww.trailers {
+		writeField(name, h.Values(name))
+	}
+	for key, values := range h {
+		if name, ok := strings.CutPrefix(key, http.TrailerPrefix); ok {
+			writeField(name, values)
+			delete(h, key) // 避免再作为 HTTP Trailer 发送
+		}
+	}
+	if trailer.Len() == 0 {
+		if ww.wroteBody || h.Get("Grpc-Status") != "" {
+			return
+		}
+		writeField("grpc-status", []string{strconv.Itoa(int(grpcstatus.Unknown))}) // 后端既没有返回响应体也没有返回状态
+	}
+
+	frame := make([]byte, 5, 5+trailer.Len())
+	frame[0] = grpcWebTrailerFlag
+	binary.BigEndian.PutUint32(frame[1:], uint32(trailer.Len()))
+	_, _ = ww.Write(append(frame, trailer.Bytes()...))
+	_ = http.NewResponseController(ww.ResponseWriter).Flush()
+}
+
+// GRPCWebPreflightFallback 跨域策略没有响应的 CORS 预检请求不转发给 gRPC 后端，直接拒绝
+func GRPCWebPreflightFallback(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions && !proxy.IsGRPCRequest(r) {
+			http.Error(w, "不允许的跨域请求", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
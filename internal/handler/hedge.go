@@ -0,0 +1,245 @@
+package handler
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+
+	"api-gateway/internal/balancer"
+	"api-gateway/internal/config"
+	"api-gateway/internal/metrics"
+	"api-gateway/pkg/circuitbreaker"
+)
+
+// 对冲请求默认参数
+const (
+	defaultHedgeDelay    = 100 * time.Millisecond
+	defaultMaxHedgeRatio = 0.1
+	latencyWindowSize    = 512 // 用于计算百分位的最近延迟样本数
+	latencyMinSamples    = 50  // 样本数不足时使用固定延迟
+	latencyRefreshEvery  = 64  // 每新增多少个样本重新计算一次百分位
+)
+
+// Hedges 管理所有路由的对冲策略，配置不变时在配置热加载后保留延迟统计
+type Hedges struct {
+	routes  map[string]*RouteHedge
+	mu      sync.Mutex
+	metrics *metrics.HedgeMetrics
+}
+
+// NewHedges 创建 Hedges
+func NewHedges(hedgeMetrics *metrics.HedgeMetrics) *Hedges {
+	return &Hedges{
+		routes:  make(map[string]*RouteHedge),
+		metrics: hedgeMetrics,
+	}
+}
+
+// Route 获取路由的对冲策略，未启用对冲时返回 nil；配置变化时重新创建
+func (h *Hedges) Route(route string, cfg config.HedgeConfig) *RouteHedge {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !cfg.Enabled {
+		delete(h.routes, route)
+		return nil
+	}
+	if rh, ok := h.routes[route]; ok && reflect.DeepEqual(rh.cfg, cfg) {
+		return rh
+	}
+
+	ratio := cfg.MaxHedgeRatio
+	if ratio <= 0 {
+		ratio = defaultMaxHedgeRatio
+	}
+	delay := cfg.Delay
+	if delay <= 0 {
+		delay = defaultHedgeDelay
+	}
+	rh := &RouteHedge{
+		route:   route,
+		cfg:     cfg,
+		delay:   delay,
+		budget:  &requestBudget{ratio: ratio},
+		latency: &latencyTracker{percentile: cfg.Percentile},
+		metrics: h.metrics,
+	}
+	h.routes[route] = rh
+	return rh
+}
+
+// RouteHedge 单个路由的对冲策略
+type RouteHedge struct {
+	route   string
+	cfg     config.HedgeConfig
+	delay   time.Duration
+	budget  *requestBudget
+	latency *latencyTracker
+	metrics *metrics.HedgeMetrics
+}
+
+// eligible 判断请求是否可以对冲：仅限只读的幂等方法，且请求体可以重新发送；协议升级请求不对冲
+func (rh *RouteHedge) eligible(req *http.Request) bool {
+	if req.Header.Get("Upgrade") != "" {
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return replayable(req)
+	default:
+		return false
+	}
+}
+
+// hedgeDelay 返回当前的对冲延迟
+func (rh *RouteHedge) hedgeDelay() time.Duration {
+	if rh.cfg.Percentile > 0 {
+		if d, ok := rh.latency.value(); ok {
+			return d
+		}
+	}
+	return rh.delay
+}
+
+// hedgedAttempt 先向一个实例发送请求，若在对冲延迟内没有响应，再向另一个实例发送相同请求；
+// 使用先成功返回的响应并取消另一个请求
+func (t *upstreamTransport) hedgedAttempt(req *http.Request, avoid []*balancer.Instance) (*http.Response, []*balancer.Instance, error) {
+	rh := t.hedge
+	rh.budget.recordRequest()
+
+	primary, cb, err := t.pick(req, avoid)
+	if err != nil {
+		return nil, nil, err
+	}
+	tried := []*balancer.Instance{primary}
+
+	type result struct {
+		resp  *http.Response
+		err   error
+		hedge bool
+	}
+	results := make(chan result, 2)
+	cancels := make(map[bool]context.CancelFunc, 2) // 是否为对冲请求 -> 取消函数
+	launch := func(r *http.Request, instance *balancer.Instance, cb *circuitbreaker.CircuitBreaker, hedge bool) {
+		ctx, cancel := context.WithCancel(r.Context())
+		cancels[hedge] = cancel
+		go func() {
+			resp, err := t.send(r.WithContext(ctx), instance, cb)
+			results <- result{resp: resp, err: err, hedge: hedge}
+		}()
+	}
+	launch(req, primary, cb, false)
+	pending := 1
+
+	timer := time.NewTimer(rh.hedgeDelay())
+	defer timer.Stop()
+
+	var lastErr error
+	for pending > 0 {
+		select {
+		case <-timer.C:
+			if pending != 1 || len(tried) != 1 || !rh.budget.allow() {
+				continue
+			}
+			second, cb2, err := t.pick(req, slices.Concat(avoid, tried))
+			if err != nil {
+				continue
+			}
+			if second == primary { // 没有其他实例可选，不对冲
+				if cb2 != nil {
+					cb2.Cancel()
+				}
+				continue
+			}
+			hedgeReq, err := rewind(req)
+			if err != nil {
+				if cb2 != nil {
+					cb2.Cancel()
+				}
+				continue
+			}
+			tried = append(tried, second)
+			rh.metrics.ObserveHedge(rh.route)
+			launch(hedgeReq, second, cb2, true)
+			pending++
+
+		case res := <-results:
+			pending--
+			if res.err != nil {
+				cancels[res.hedge]()
+				lastErr = res.err
+				continue
+			}
+			if res.hedge {
+				rh.metrics.ObserveWin(rh.route)
+			}
+			// 取消仍在进行的请求，并丢弃其稍后到达的响应
+			if pending > 0 {
+				cancels[!res.hedge]()
+				go func(n int) {
+					for ; n > 0; n-- {
+						if late := <-results; late.resp != nil {
+							late.resp.Body.Close()
+						}
+					}
+				}(pending)
+			}
+			res.resp.Body = &cancelOnClose{ReadCloser: res.resp.Body, cancel: cancels[res.hedge]}
+			return res.resp, tried, nil
+		}
+	}
+	return nil, tried, lastErr
+}
+
+// cancelOnClose 在响应体关闭时取消该请求的上下文
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// latencyTracker 记录最近的响应延迟并计算百分位
+type latencyTracker struct {
+	percentile  float64
+	mu          sync.Mutex
+	samples     [latencyWindowSize]time.Duration
+	count       int // 已记录的样本总数
+	sinceUpdate int
+	cached      time.Duration
+}
+
+// observe 记录一次响应延迟
+func (lt *latencyTracker) observe(d time.Duration) {
+	if lt.percentile <= 0 {
+		return
+	}
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	lt.samples[lt.count%latencyWindowSize] = d
+	lt.count++
+	lt.sinceUpdate++
+	if lt.count >= latencyMinSamples && (lt.cached == 0 || lt.sinceUpdate >= latencyRefreshEvery) {
+		n := min(lt.count, latencyWindowSize)
+		sorted := slices.Clone(lt.samples[:n])
+		slices.Sort(sorted)
+		lt.cached = sorted[min(int(float64(n)*lt.percentile), n-1)]
+		lt.sinceUpdate = 0
+	}
+}
+
+// value 返回最近延迟的百分位，样本不足时返回 false
+func (lt *latencyTracker) value() (time.Duration, bool) {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+	return lt.cached, lt.count >= latencyMinSamples && lt.cached > 0
+}
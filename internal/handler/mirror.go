@@ -0,0 +1,206 @@
+package handler
+
+import (
+	"context"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"api-gateway/internal/balancer"
+	"api-gateway/internal/config"
+	"api-gateway/internal/metrics"
+	"api-gateway/internal/proxy"
+	"go.uber.org/zap"
+)
+
+// 流量镜像默认参数
+const (
+	defaultMirrorTimeout       = 5 * time.Second
+	defaultMirrorMaxConcurrent = 100
+	defaultMirrorMaxBodyBytes  = 64 << 10
+	mirrorMaxDiscardBytes      = 1 << 20 // 读取并丢弃的影子响应体上限，超过后直接关闭连接
+)
+
+// Mirror 将路由的请求副本异步发送到影子后端，丢弃其响应，只记录与主请求的状态码与延迟差异
+type Mirror struct {
+	routeID      string
+	reverseProxy *proxy.ReverseProxy
+	upstream     *proxy.Upstream
+	balancer     balancer.Balancer
+	percent      float64
+	timeout      time.Duration
+	maxBodyBytes int64
+	slots        chan struct{} // 限制同时进行的镜像请求数
+	metrics      *metrics.MirrorMetrics
+	logger       *zap.Logger
+}
+
+// NewMirror 创建转发到影子后端 upstream 的 Mirror
+func NewMirror(routeID string, reverseProxy *proxy.ReverseProxy, upstream *proxy.Upstream, lb balancer.Balancer, cfg config.MirrorConfig, mirrorMetrics *metrics.MirrorMetrics, logger *zap.Logger) *Mirror {
+	m := &Mirror{
+		routeID:      routeID,
+		reverseProxy: reverseProxy,
+		upstream:     upstream,
+		balancer:     lb,
+		percent:      cfg.Percent,
+		timeout:      cfg.Timeout,
+		maxBodyBytes: cfg.MaxBodyBytes,
+		metrics:      mirrorMetrics,
+		logger:       logger,
+	}
+	if m.percent <= 0 || m.percent > 100 {
+		m.percent = 100
+	}
+	if m.timeout <= 0 {
+		m.timeout = defaultMirrorTimeout
+	}
+	if m.maxBodyBytes <= 0 {
+		m.maxBodyBytes = defaultMirrorMaxBodyBytes
+	}
+	maxConcurrent := cfg.MaxConcurrent
+	if maxConcurrent <= 0 {
+		maxConcurrent = defaultMirrorMaxConcurrent
+	}
+	m.slots = make(chan struct{}, maxConcurrent)
+	return m
+}
+
+// sample 按比例决定是否镜像本次请求
+func (m *Mirror) sample() bool {
+	return m.percent >= 100 || rand.Float64()*100 < m.percent
+}
+
+// mirrorResult 主请求的结果
+type mirrorResult struct {
+	status  int
+	latency time.Duration
+}
+
+// start 异步发送 req 的副本 (请求体须已缓冲)，返回用于提交主请求结果的 channel；未能镜像时返回 nil。
+// channel 带缓冲，主请求提交结果时不会等待镜像请求
+func (m *Mirror) start(req *http.Request) chan<- mirrorResult {
+	if !replayable(req) || req.ContentLength > m.maxBodyBytes {
+		reason := "body_too_large"
+		if streamingBody(req) {
+			reason = "streaming_body"
+		}
+		m.metrics.ObserveSkipped(m.routeID, reason)
+		return nil
+	}
+	select {
+	case m.slots <- struct{}{}:
+	default:
+		m.metrics.ObserveSkipped(m.routeID, "concurrency_limit")
+		return nil
+	}
+
+	// 镜像请求不随客户端请求取消，使用独立的超时
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(req.Context()), m.timeout)
+	mreq := req.Clone(ctx)
+	mreq.RequestURI = ""
+	forwardHeaders(mreq)
+	mreq.Header.Set("X-Gateway-Mirror", "true")
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			cancel()
+			<-m.slots
+			m.metrics.ObserveSkipped(m.routeID, "body_error")
+			return nil
+		}
+		mreq.Body = body
+	}
+
+	primary := make(chan mirrorResult, 1)
+	go func() {
+		defer func() { <-m.slots }()
+		defer cancel()
+
+		start := time.Now()
+		status, err := m.send(mreq)
+		latency := time.Since(start)
+
+		result := <-primary
+		m.metrics.ObserveMirror(m.routeID, status, result.status, latency, result.latency)
+		fields := []zap.Field{
+			zap.String("route", m.routeID), zap.String("upstream", m.upstream.Name()), zap.String("path", mreq.URL.Path),
+			zap.Int("primary_status", result.status), zap.Int("mirror_status", status),
+			zap.Duration("primary_latency", result.latency), zap.Duration("mirror_latency", latency),
+		}
+		if err != nil {
+			m.logger.Info("镜像请求失败", append(fields, zap.Error(err))...)
+		} else if status != result.status {
+			m.logger.Info("镜像请求状态码与主请求不一致", fields...)
+		} else {
+			m.logger.Debug("镜像请求完成", fields...)
+		}
+	}()
+	return primary
+}
+
+// hopHeaders 逐跳请求头，只在客户端与网关之间有效，不转发给后端 (同 httputil.ReverseProxy)
+var hopHeaders = []string{
+	"Connection",
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// forwardHeaders 按 httputil.ReverseProxy 转发主请求的方式处理镜像请求的请求头：
+// 移除逐跳请求头与 Connection 中列出的请求头，并将客户端地址追加到 X-Forwarded-For
+func forwardHeaders(req *http.Request) {
+	h := req.Header
+	for _, value := range h.Values("Connection") {
+		for name := range strings.SplitSeq(value, ",") {
+			if name = textproto.TrimString(name); name != "" {
+				h.Del(name)
+			}
+		}
+	}
+	trailers := false
+	for _, value := range h.Values("Te") {
+		for token := range strings.SplitSeq(value, ",") {
+			trailers = trailers || strings.EqualFold(textproto.TrimString(token), "trailers")
+		}
+	}
+	for _, name := range hopHeaders {
+		h.Del(name)
+	}
+	if trailers { // 与主请求一致，保留 gRPC 等需要的 "Te: trailers"
+		h.Set("Te", "trailers")
+	}
+	if _, ok := h["User-Agent"]; !ok {
+		h.Set("User-Agent", "") // 不使用 Go 默认的 User-Agent
+	}
+
+	if clientIP, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+		if prior := h.Values("X-Forwarded-For"); len(prior) > 0 {
+			clientIP = strings.Join(prior, ", ") + ", " + clientIP
+		}
+		h.Set("X-Forwarded-For", clientIP)
+	}
+}
+
+// send 将镜像请求发送到影子后端并丢弃响应，返回响应状态码
+func (m *Mirror) send(req *http.Request) (int, error) {
+	instance, err := m.upstream.Pick(req, m.balancer)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := m.reverseProxy.RoundTrip(req, m.upstream, instance)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, mirrorMaxDiscardBytes))
+	return resp.StatusCode, nil
+}
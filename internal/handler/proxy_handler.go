@@ -2,41 +2,131 @@ package handler
 
 import (
 	"context"
+	"fmt"
 	"net/http"
-	"net/http/httputil"
 	"time"
 
+	"api-gateway/internal/balancer"
+	"api-gateway/internal/config"
+	"api-gateway/internal/proxy"
+	"api-gateway/internal/router"
 	"go.uber.org/zap"
 )
 
-// ProxyHandler 创建反向代理处理函数
-func ProxyHandler(proxy *httputil.ReverseProxy, targetURL string, timeout time.Duration, logger *zap.Logger) http.HandlerFunc {
-	director := func(req *http.Request) {
-		req.URL.Scheme = "http" // 或 "https"
-		req.URL.Host = targetURL
-		req.Host = targetURL
+// ProxyOptions 路由转发选项
+type ProxyOptions struct {
+	Timeout         time.Duration               // 请求超时
+	CircuitBreakers *RouteBreakers              // 路由的熔断器组，未启用熔断时为 nil
+	Retry           config.RetryConfig          // 重试策略
+	AffinityCookie  config.AffinityCookieConfig // 网关签发的亲和性 Cookie
+	Hedge           *RouteHedge                 // 路由的对冲策略，未启用对冲时为 nil
+	Rewrite         *PathRewriter               // 路径重写，未配置时为 nil
+	Mirror          *Mirror                     // 流量镜像，未启用时为 nil
+	WebSocket       *RouteWebSocket             // WebSocket 升级策略，未启用时为 nil
+	GRPC            bool                        // gRPC 路由：超时按客户端的 grpc-timeout 设置，不超过 Timeout
+	StreamTimeout   time.Duration               // gRPC 请求未携带 grpc-timeout 时的超时，0 表示不限制
+}
 
-		// 请求头转换示例：添加自定义请求头
-		req.Header.Set("X-Gateway-Request", "true")
-		// 可以根据需要删除或修改其他请求头
+// ProxyHandler 创建反向代理处理函数，每个请求由 lb 从 upstream 中选择实例
+func ProxyHandler(reverseProxy *proxy.ReverseProxy, upstream *proxy.Upstream, lb balancer.Balancer, opts ProxyOptions, logger *zap.Logger) (http.HandlerFunc, error) {
+	retry, err := newRetryPolicy(opts.Retry)
+	if err != nil {
+		return nil, fmt.Errorf("解析重试策略失败: %w", err)
 	}
+	p := reverseProxy.GetProxy(upstream, &upstreamTransport{
+		reverseProxy: reverseProxy,
+		upstream:     upstream,
+		balancer:     lb,
+		breakers:     opts.CircuitBreakers,
+		retry:        retry,
+		hedge:        opts.Hedge,
+		logger:       logger,
+	})
 
 	return func(w http.ResponseWriter, r *http.Request) {
-		ctx, cancel := context.WithTimeout(r.Context(), timeout) // 设置请求超时
-		defer cancel()
-
-		proxy.Director = director
-		proxy.ErrorHandler = func(rw http.ResponseWriter, req *http.Request, err error) { // 自定义错误处理
-			logger.Error("反向代理请求失败",
-				zap.String("path", req.URL.Path),
-				zap.String("target_url", targetURL),
-				zap.Error(err),
-			)
-			rw.WriteHeader(http.StatusBadGateway) // 返回 502 Bad Gateway
-			_, _ = rw.Write([]byte("后端服务不可用"))
+		if opts.WebSocket != nil && isWebSocketUpgrade(r) { // WebSocket 长连接不受请求超时限制
+			opts.WebSocket.serve(w, r, opts.Timeout, func(w http.ResponseWriter, r *http.Request) {
+				p.ServeHTTP(w, outboundRequest(w, r, opts))
+			})
+			return
+		}
+
+		// 设置请求超时，以 proxy.ErrRouteTimeout 为原因，熔断与异常检测据此区分路由超时与客户端取消
+		ctx := r.Context()
+		if timeout := requestTimeout(r, opts); timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeoutCause(ctx, timeout, proxy.ErrRouteTimeout)
+			defer cancel()
+		}
+
+		// 缓冲请求体，使请求可以被重试与镜像；gRPC 与长度未知的请求体可能是持续发送的流，缓冲会阻塞转发，保持流式转发
+		mirrored := opts.Mirror != nil && opts.Mirror.sample()
+		retryable := retry != nil && retry.mayRetry(r.Method)
+		if (retryable || mirrored) && !streamingBody(r) {
+			var maxBodyBytes int64
+			if retryable {
+				maxBodyBytes = retry.maxBodyBytes
+			}
+			if mirrored { // 按两者中较大的上限缓冲，重试与镜像发送前各自检查自己的上限
+				maxBodyBytes = max(maxBodyBytes, opts.Mirror.maxBodyBytes)
+			}
+			if err := bufferRequestBody(r, maxBodyBytes); err != nil {
+				logger.Warn("读取请求体失败", zap.String("path", r.URL.Path), zap.Error(err))
+				http.Error(w, "读取请求体失败", http.StatusBadRequest)
+				return
+			}
 		}
 
-		// 使用 context.WithTimeout 创建带有超时控制的请求
-		proxy.ServeHTTP(w, r.WithContext(ctx))
+		// 使用带有超时控制的 context 创建请求
+		outreq := outboundRequest(w, r.WithContext(ctx), opts)
+
+		if mirrored {
+			if primary := opts.Mirror.start(outreq); primary != nil {
+				start := time.Now()
+				sw := &statusWriter{ResponseWriter: w, statusCode: http.StatusOK}
+				defer func() { primary <- mirrorResult{status: sw.statusCode, latency: time.Since(start)} }()
+				w = sw
+			}
+		}
+		p.ServeHTTP(w, outreq)
+	}, nil
+}
+
+// requestTimeout 返回请求的超时时间，0 表示不限制：gRPC 请求取客户端 grpc-timeout 与路由 timeout 中较小者，
+// 未携带 grpc-timeout 时使用 StreamTimeout，避免流式 RPC 被路由 timeout 中断
+func requestTimeout(r *http.Request, opts ProxyOptions) time.Duration {
+	if !opts.GRPC || !proxy.IsGRPCRequest(r) {
+		return opts.Timeout
+	}
+	if timeout, ok := grpcTimeout(r); ok {
+		return min(timeout, opts.Timeout)
+	}
+	return opts.StreamTimeout
+}
+
+// streamingBody 判断请求体是否可能是流：gRPC 请求 (客户端流与双向流 RPC) 或长度未知的请求体
+func streamingBody(r *http.Request) bool {
+	return r.Body != nil && r.Body != http.NoBody && (r.ContentLength < 0 || proxy.IsGRPCRequest(r))
+}
+
+// outboundRequest 生成转发到后端的请求：签发亲和性 Cookie、添加网关请求头并重写路径，r 须为 WithContext 生成的副本
+func outboundRequest(w http.ResponseWriter, r *http.Request, opts ProxyOptions) *http.Request {
+	if opts.AffinityCookie.Enabled {
+		r = applyAffinityCookie(w, r, opts.AffinityCookie)
+	}
+
+	// 请求头转换示例：添加自定义请求头
+	r.Header.Set("X-Gateway-Request", "true")
+	// 可以根据需要删除或修改其他请求头
+
+	if opts.Rewrite != nil { // r 是 WithContext 生成的浅拷贝，替换 URL 不影响原请求
+		u := *r.URL
+		u.Path = opts.Rewrite.Rewrite(r.URL.Path, router.PathCaptures(r))
+		u.RawPath = ""
+		if r.URL.RawPath != "" { // 按同样的规则重写转义形式的路径以保留 %2F 等转义，与新路径不一致时 URL 会忽略 RawPath
+			u.RawPath = opts.Rewrite.Rewrite(r.URL.RawPath, router.RawPathCaptures(r))
+		}
+		r.URL = &u
 	}
+	return r
 }
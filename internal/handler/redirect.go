@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"api-gateway/internal/config"
+	"api-gateway/internal/router"
+)
+
+// redirectStatusCodes 支持的重定向状态码
+var redirectStatusCodes = []int{
+	http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+	http.StatusTemporaryRedirect, http.StatusPermanentRedirect,
+}
+
+// RedirectHandler 创建重定向处理函数，rewrite 不为 nil 时作用于重定向目标的路径
+func RedirectHandler(cfg config.RedirectConfig, rewrite *PathRewriter) (http.HandlerFunc, error) {
+	status := cfg.StatusCode
+	if status == 0 {
+		status = http.StatusFound
+	}
+	if !slices.Contains(redirectStatusCodes, status) {
+		return nil, fmt.Errorf("不支持的重定向状态码: %d", cfg.StatusCode)
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+		if rewrite != nil {
+			path = rewrite.Rewrite(path, router.PathCaptures(r))
+		}
+
+		var location string
+		if cfg.Location != "" {
+			vars := map[string]string{
+				"scheme": requestScheme(r),
+				"host":   r.Host,
+				"path":   path,
+				"query":  r.URL.RawQuery,
+			}
+			for name, value := range router.PathCaptures(r) {
+				vars[name] = value
+			}
+			location = templateVarRegexp.ReplaceAllStringFunc(cfg.Location, func(v string) string {
+				return vars[v[1:len(v)-1]]
+			})
+		} else {
+			u := url.URL{Scheme: requestScheme(r), Host: r.Host, Path: path, RawQuery: r.URL.RawQuery}
+			if cfg.HTTPSUpgrade {
+				u.Scheme = "https"
+				if host, _, err := net.SplitHostPort(u.Host); err == nil {
+					u.Host = host
+				}
+			}
+			if cfg.Host != "" {
+				u.Host = cfg.Host
+			}
+			if cfg.StripQuery {
+				u.RawQuery = ""
+			}
+			location = u.String()
+		}
+		http.Redirect(w, r, location, status)
+	}, nil
+}
+
+// HTTPSRedirectHandler 将请求永久重定向到 HTTPS 端口，308 保留请求方法与请求体；httpsPort 为 443 时 Location 中省略端口
+func HTTPSRedirectHandler(httpsPort int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(httpsPort))
+		} else if strings.Contains(host, ":") { // IPv6 地址
+			host = "[" + host + "]"
+		}
+		u := url.URL{Scheme: "https", Host: host, Path: r.URL.Path, RawPath: r.URL.RawPath, RawQuery: r.URL.RawQuery}
+		http.Redirect(w, r, u.String(), http.StatusPermanentRedirect)
+	}
+}
+
+// requestScheme 返回客户端请求使用的协议，经过前置代理时以 X-Forwarded-Proto 为准
+func requestScheme(r *http.Request) string {
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		return strings.ToLower(strings.TrimSpace(strings.Split(proto, ",")[0]))
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
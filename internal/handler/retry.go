@@ -0,0 +1,262 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"api-gateway/internal/config"
+)
+
+// 可重试条件
+const (
+	retryOnConnectFailure = "connect_failure"
+	retryOnReset          = "reset"
+)
+
+// 重试策略默认参数
+const (
+	defaultRetryAttempts       = 3
+	defaultRetryBackoffBase    = 25 * time.Millisecond
+	defaultRetryBackoffMax     = 250 * time.Millisecond
+	defaultRetryBudgetRatio    = 0.2
+	defaultMinRetriesPerSecond = 10
+	defaultRetryMaxBodyBytes   = 64 << 10
+)
+
+// retryPolicy 路由的重试策略
+type retryPolicy struct {
+	attempts           int
+	retryOnConnect     bool
+	retryOnReset       bool
+	retryStatuses      []int
+	retryNonIdempotent bool
+	backoffBase        time.Duration
+	backoffMax         time.Duration
+	maxBodyBytes       int64
+	budget             *requestBudget
+}
+
+// newRetryPolicy 根据配置创建重试策略，未启用时返回 nil；retry_on 中有无法识别的条件时返回错误
+func newRetryPolicy(cfg config.RetryConfig) (*retryPolicy, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	rp := &retryPolicy{
+		attempts:           cfg.Attempts,
+		retryNonIdempotent: cfg.RetryNonIdempotent,
+		backoffBase:        cfg.BackoffBase,
+		backoffMax:         cfg.BackoffMax,
+		maxBodyBytes:       cfg.MaxBodyBytes,
+	}
+	if rp.attempts <= 0 {
+		rp.attempts = defaultRetryAttempts
+	}
+	if rp.backoffBase <= 0 {
+		rp.backoffBase = defaultRetryBackoffBase
+	}
+	if rp.backoffMax <= 0 {
+		rp.backoffMax = defaultRetryBackoffMax
+	}
+	if rp.maxBodyBytes <= 0 {
+		rp.maxBodyBytes = defaultRetryMaxBodyBytes
+	}
+
+	retryOn := cfg.RetryOn
+	if len(retryOn) == 0 {
+		retryOn = []string{retryOnConnectFailure, strconv.Itoa(http.StatusServiceUnavailable)}
+	}
+	for _, cond := range retryOn {
+		switch cond {
+		case retryOnConnectFailure:
+			rp.retryOnConnect = true
+		case retryOnReset:
+			rp.retryOnReset = true
+		default:
+			code, err := strconv.Atoi(cond)
+			if err != nil || code < 100 || code > 599 {
+				return nil, fmt.Errorf("无效的重试条件 %q，可选 %s、%s 或 HTTP 状态码", cond, retryOnConnectFailure, retryOnReset)
+			}
+			rp.retryStatuses = append(rp.retryStatuses, code)
+		}
+	}
+
+	ratio := cfg.BudgetRatio
+	if ratio <= 0 {
+		ratio = defaultRetryBudgetRatio
+	}
+	minPerSecond := cfg.MinRetriesPerSecond
+	if minPerSecond <= 0 {
+		minPerSecond = defaultMinRetriesPerSecond
+	}
+	rp.budget = &requestBudget{
+		ratio:      ratio,
+		minAllowed: minPerSecond * int(budgetWindow/time.Second),
+	}
+	return rp, nil
+}
+
+// replayable 判断请求体是否可以在重试时重新发送
+func replayable(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// canReplay 判断请求体是否可以在重试时重新发送：镜像可能按更大的上限缓冲了请求体，超过重试上限的请求体不重试
+func (rp *retryPolicy) canReplay(req *http.Request) bool {
+	return replayable(req) && req.ContentLength <= rp.maxBodyBytes
+}
+
+// shouldRetry 判断一次尝试的结果是否满足重试条件
+func (rp *retryPolicy) shouldRetry(req *http.Request, resp *http.Response, err error) bool {
+	if !rp.canReplay(req) || req.Context().Err() != nil {
+		return false
+	}
+	if err != nil {
+		// 连接失败时请求尚未到达后端，对任何方法都可以安全重试
+		if isConnectFailure(err) {
+			return rp.retryOnConnect
+		}
+		return rp.retryOnReset && isReset(err) && rp.methodRetryable(req.Method)
+	}
+	return slices.Contains(rp.retryStatuses, resp.StatusCode) && rp.methodRetryable(req.Method)
+}
+
+// mayRetry 判断该方法的请求是否可能被重试：连接失败对任何方法都可以重试，其余条件取决于方法
+func (rp *retryPolicy) mayRetry(method string) bool {
+	return rp.retryOnConnect || rp.methodRetryable(method) && (rp.retryOnReset || len(rp.retryStatuses) > 0)
+}
+
+// methodRetryable 判断请求方法是否允许在请求已到达后端后重试
+func (rp *retryPolicy) methodRetryable(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return rp.retryNonIdempotent
+	}
+}
+
+// backoff 返回第 retry 次重试前的等待时间 (指数退避 + 全抖动)
+func (rp *retryPolicy) backoff(retry int) time.Duration {
+	ceiling := min(rp.backoffBase<<(retry-1), rp.backoffMax)
+	if ceiling <= 0 {
+		return 0
+	}
+	return rand.N(ceiling + 1)
+}
+
+// bufferRequestBody 读取不超过 maxBytes 的请求体到内存并设置 GetBody，使其可以在重试与镜像时重新发送；
+// 超过上限的请求体保持流式转发 (不可重试、不镜像)
+func bufferRequestBody(r *http.Request, maxBytes int64) error {
+	if r.Body == nil || r.Body == http.NoBody || r.GetBody != nil {
+		return nil
+	}
+	if r.ContentLength > maxBytes {
+		return nil
+	}
+	buf, err := io.ReadAll(io.LimitReader(r.Body, maxBytes+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(buf)) > maxBytes {
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(buf), r.Body), r.Body}
+		return nil
+	}
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(buf))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(buf)), nil
+	}
+	return nil
+}
+
+// rewind 为重试构造带有新请求体的请求副本
+func rewind(req *http.Request) (*http.Request, error) {
+	if req.GetBody == nil {
+		return req, nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	retryReq := *req
+	retryReq.Body = body
+	return &retryReq, nil
+}
+
+// sleepContext 等待 d，ctx 取消时提前返回错误
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// isConnectFailure 判断错误是否为建立连接失败
+func isConnectFailure(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// isReset 判断错误是否为连接被重置或提前关闭
+func isReset(err error) bool {
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// budgetWindow 请求预算的统计窗口
+const budgetWindow = 10 * time.Second
+
+// requestBudget 请求预算：限制统计窗口内额外请求 (重试、对冲) 数占原始请求数的比例，避免在故障时放大流量
+type requestBudget struct {
+	ratio       float64
+	minAllowed  int // 每个窗口至少允许的额外请求数
+	mu          sync.Mutex
+	windowStart time.Time
+	requests    int
+	extra       int
+}
+
+// rotate 超过统计窗口时重置计数，调用方需持有锁
+func (b *requestBudget) rotate() {
+	if now := time.Now(); now.Sub(b.windowStart) >= budgetWindow {
+		b.windowStart = now
+		b.requests, b.extra = 0, 0
+	}
+}
+
+// recordRequest 记录一次原始请求
+func (b *requestBudget) recordRequest() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rotate()
+	b.requests++
+}
+
+// allow 判断是否还有预算发送额外请求，有则占用一次
+func (b *requestBudget) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rotate()
+	if b.extra >= max(b.minAllowed, int(float64(b.requests)*b.ratio)) {
+		return false
+	}
+	b.extra++
+	return true
+}
@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"api-gateway/internal/config"
+)
+
+// templateVarRegexp 匹配路径模板中的 {name} 占位符
+var templateVarRegexp = regexp.MustCompile(`\{(\w+)\}`)
+
+// PathRewriter 转发前的路径重写
+type PathRewriter struct {
+	stripPrefix string
+	addPrefix   string
+	regex       *regexp.Regexp
+	replacement string
+	template    string
+}
+
+// NewPathRewriter 根据配置创建 PathRewriter，未配置任何重写时返回 nil；
+// captureNames 为路由 regex 路径可引用的捕获组，模板引用了其他占位符时返回错误
+func NewPathRewriter(cfg config.RewriteConfig, captureNames []string) (*PathRewriter, error) {
+	if cfg == (config.RewriteConfig{}) {
+		return nil, nil
+	}
+	for _, match := range templateVarRegexp.FindAllStringSubmatch(cfg.Template, -1) {
+		if !slices.Contains(captureNames, match[1]) {
+			return nil, fmt.Errorf("路径模板引用了不存在的捕获组 %s", match[0])
+		}
+	}
+	pr := &PathRewriter{
+		stripPrefix: cfg.StripPrefix,
+		addPrefix:   strings.TrimSuffix(cfg.AddPrefix, "/"),
+		replacement: cfg.Replacement,
+		template:    cfg.Template,
+	}
+	if cfg.Regex != "" {
+		re, err := regexp.Compile(cfg.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("路径重写正则表达式无效: %w", err)
+		}
+		pr.regex = re
+	}
+	return pr, nil
+}
+
+// Rewrite 返回重写后的路径，captures 为路由 regex 路径的捕获组
+func (pr *PathRewriter) Rewrite(path string, captures map[string]string) string {
+	if pr.template != "" {
+		return ensureLeadingSlash(templateVarRegexp.ReplaceAllStringFunc(pr.template, func(v string) string {
+			return captures[v[1:len(v)-1]]
+		}))
+	}
+	if pr.stripPrefix != "" {
+		if rest, ok := strings.CutPrefix(path, pr.stripPrefix); ok {
+			path = ensureLeadingSlash(rest)
+		}
+	}
+	if pr.regex != nil {
+		path = pr.regex.ReplaceAllString(path, pr.replacement)
+	}
+	if pr.addPrefix != "" {
+		if path == "" || path == "/" {
+			return pr.addPrefix
+		}
+		path = pr.addPrefix + ensureLeadingSlash(path)
+	}
+	return path
+}
+
+// ensureLeadingSlash 保证路径以 / 开头
+func ensureLeadingSlash(path string) string {
+	if !strings.HasPrefix(path, "/") {
+		return "/" + path
+	}
+	return path
+}
@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"bufio"
+	"hash/fnv"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"time"
+
+	"api-gateway/internal/balancer"
+	"api-gateway/internal/metrics"
+)
+
+// Backend 路由的一个后端，Handler 负责转发到该后端
+type Backend struct {
+	Name    string
+	Weight  int
+	Handler http.HandlerFunc
+}
+
+// TrafficSplitHandler 按权重在多个后端之间拆分流量，权重均不为负且之和大于 0 (由调用方校验)；
+// sticky 不为 nil 且请求中存在粘性键时，相同键的请求固定到同一后端
+func TrafficSplitHandler(routeID string, backends []Backend, sticky balancer.KeyFunc, backendMetrics *metrics.BackendMetrics) http.HandlerFunc {
+	total := 0
+	for _, b := range backends {
+		total += b.Weight
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		var point int
+		if key, ok := stickyKey(r, sticky); ok {
+			h := fnv.New64a()
+			h.Write([]byte(key))
+			point = int(h.Sum64() % uint64(total))
+		} else {
+			point = rand.IntN(total)
+		}
+		backend := pickBackend(backends, point)
+		serveBackend(w, r, routeID, backend, backendMetrics)
+	}
+}
+
+// pickBackend 返回权重区间包含 point 的后端
+func pickBackend(backends []Backend, point int) Backend {
+	for _, b := range backends {
+		if point < b.Weight {
+			return b
+		}
+		point -= b.Weight
+	}
+	return backends[len(backends)-1]
+}
+
+// stickyKey 提取流量拆分的粘性键
+func stickyKey(r *http.Request, sticky balancer.KeyFunc) (string, bool) {
+	if sticky == nil {
+		return "", false
+	}
+	return sticky(r)
+}
+
+// serveBackend 将请求交给后端处理，并记录按后端区分的指标
+func serveBackend(w http.ResponseWriter, r *http.Request, routeID string, backend Backend, backendMetrics *metrics.BackendMetrics) {
+	start := time.Now()
+	sw := &statusWriter{ResponseWriter: w, statusCode: http.StatusOK}
+	backend.Handler(sw, r)
+	backendMetrics.Observe(routeID, backend.Name, sw.statusCode, time.Since(start))
+}
+
+// statusWriter 记录响应状态码
+type statusWriter struct {
+	http.ResponseWriter
+	statusCode  int
+	wroteHeader bool
+}
+
+func (sw *statusWriter) WriteHeader(code int) {
+	if !sw.wroteHeader {
+		sw.statusCode = code
+		sw.wroteHeader = true
+	}
+	sw.ResponseWriter.WriteHeader(code)
+}
+
+func (sw *statusWriter) Write(b []byte) (int, error) {
+	sw.wroteHeader = true
+	return sw.ResponseWriter.Write(b)
+}
+
+// Hijack 透传连接劫持，使 WebSocket 等协议升级可以穿过
+func (sw *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(sw.ResponseWriter).Hijack()
+	if err == nil && !sw.wroteHeader {
+		sw.statusCode = http.StatusSwitchingProtocols
+		sw.wroteHeader = true
+	}
+	return conn, brw, err
+}
+
+// Flush 透传流式响应的刷新
+func (sw *statusWriter) Flush() {
+	if f, ok := sw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap 返回被包装的 ResponseWriter，供 http.ResponseController 使用
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
+// BackendRule 按请求条件选择后端的规则
+type BackendRule struct {
+	Match   func(r *http.Request) bool
+	Backend Backend
+}
+
+// BackendRulesHandler 按顺序匹配规则，命中时转发到规则的后端，都未命中时交给 fallback (默认后端)
+func BackendRulesHandler(routeID string, rules []BackendRule, fallback http.HandlerFunc, backendMetrics *metrics.BackendMetrics) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		for _, rule := range rules {
+			if rule.Match(r) {
+				serveBackend(w, r, routeID, rule.Backend, backendMetrics)
+				return
+			}
+		}
+		fallback(w, r)
+	}
+}
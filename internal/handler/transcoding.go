@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"api-gateway/internal/transcoder"
+)
+
+// maxTranscodingResponse 缓冲的 gRPC 响应体上限
+const maxTranscodingResponse = 8 << 20
+
+// TranscodingHandler 包装转发处理函数：将 REST/JSON 请求转码为 gRPC 请求交给 next 转发，
+// 缓冲 gRPC 响应后转换为 JSON，gRPC 错误按状态码映射为 HTTP 状态码
+func TranscodingHandler(t *transcoder.Transcoder, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		call, err := t.Transcode(r)
+		if err != nil {
+			status, body := transcoder.ErrorJSON(err)
+			writeTranscodedJSON(w, status, body)
+			return
+		}
+
+		rec := &transcodingRecorder{header: make(http.Header)}
+		next(rec, call.Request)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		if rec.overflow {
+			rec.status = http.StatusBadGateway
+			rec.body.Reset()
+			rec.body.WriteString(errTranscodingResponseTooLarge.Error())
+		}
+		status, body := call.Response(rec.status, rec.header, rec.body.Bytes())
+
+		for key, values := range rec.header { // 透传后端的自定义响应头，gRPC 协议相关的头与 trailer 除外
+			lower := strings.ToLower(key)
+			if strings.HasPrefix(lower, "grpc-") || strings.HasPrefix(key, http.TrailerPrefix) ||
+				lower == "content-type" || lower == "content-length" || lower == "trailer" {
+				continue
+			}
+			w.Header()[key] = values
+		}
+		writeTranscodedJSON(w, status, body)
+	}
+}
+
+// writeTranscodedJSON 写出 JSON 响应
+func writeTranscodedJSON(w http.ResponseWriter, status int, body []byte) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
+
+// errTranscodingResponseTooLarge gRPC 响应超过缓冲上限
+var errTranscodingResponseTooLarge = errors.New("gRPC 响应过大")
+
+// transcodingRecorder 缓冲后端的 gRPC 响应，ReverseProxy 写出的 trailer 同样记录在 header 中
+type transcodingRecorder struct {
+	header   http.Header
+	status   int
+	body     bytes.Buffer
+	overflow bool
+}
+
+func (rec *transcodingRecorder) Header() http.Header {
+	return rec.header
+}
+
+func (rec *transcodingRecorder) WriteHeader(code int) {
+	if rec.status == 0 && code >= http.StatusOK {
+		rec.status = code
+	}
+}
+
+func (rec *transcodingRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	if rec.body.Len()+len(b) > maxTranscodingResponse {
+		rec.overflow = true
+		return 0, errTranscodingResponseTooLarge
+	}
+	return rec.body.Write(b)
+}
+
+// Flush 响应转换完成后才写出，ReverseProxy 的刷新无需处理
+func (rec *transcodingRecorder) Flush() {}
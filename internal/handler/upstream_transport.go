@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"api-gateway/internal/balancer"
+	"api-gateway/internal/proxy"
+	"api-gateway/pkg/circuitbreaker"
+	"go.uber.org/zap"
+)
+
+// upstreamTransport 路由级 Transport：为每个请求选择实例，在实例熔断时改选其他实例，并按策略重试、对冲
+type upstreamTransport struct {
+	reverseProxy *proxy.ReverseProxy
+	upstream     *proxy.Upstream
+	balancer     balancer.Balancer
+	breakers     *RouteBreakers // 未启用熔断时为 nil
+	retry        *retryPolicy   // 未启用重试时为 nil
+	hedge        *RouteHedge    // 未启用对冲时为 nil
+	logger       *zap.Logger
+}
+
+// RoundTrip 实现 http.RoundTripper
+func (t *upstreamTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.retry == nil {
+		resp, _, err := t.tryOnce(req, nil)
+		return resp, err
+	}
+
+	t.retry.budget.recordRequest()
+	var tried []*balancer.Instance
+	attemptReq := req
+	for n := 1; ; n++ {
+		resp, instances, err := t.tryOnce(attemptReq, tried)
+		tried = append(tried, instances...)
+		if n >= t.retry.attempts || !t.retry.shouldRetry(attemptReq, resp, err) || !t.retry.budget.allow() {
+			return resp, err
+		}
+
+		fields := []zap.Field{zap.String("path", req.URL.Path), zap.String("upstream", t.upstream.Name()), zap.Int("attempt", n)}
+		if resp != nil {
+			fields = append(fields, zap.Int("status_code", resp.StatusCode))
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10)) // 读完少量响应体以便复用连接
+			resp.Body.Close()
+		} else {
+			fields = append(fields, zap.Error(err))
+		}
+		t.logger.Debug("请求失败，准备重试", fields...)
+
+		if err := sleepContext(req.Context(), t.retry.backoff(n)); err != nil {
+			return nil, err
+		}
+		if attemptReq, err = rewind(req); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// tryOnce 完成一次尝试 (启用对冲时可能同时请求两个实例)，返回本次尝试使用过的实例
+func (t *upstreamTransport) tryOnce(req *http.Request, avoid []*balancer.Instance) (*http.Response, []*balancer.Instance, error) {
+	if t.hedge != nil && t.hedge.eligible(req) {
+		return t.hedgedAttempt(req, avoid)
+	}
+	instance, cb, err := t.pick(req, avoid)
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, err := t.send(req, instance, cb)
+	return resp, []*balancer.Instance{instance}, err
+}
+
+// pick 选择一个实例并占用其熔断器名额，优先选择 avoid 之外的实例；未启用熔断时返回的熔断器为 nil
+func (t *upstreamTransport) pick(req *http.Request, avoid []*balancer.Instance) (*balancer.Instance, *circuitbreaker.CircuitBreaker, error) {
+	var skipped []*balancer.Instance // 已熔断而跳过的实例
+	for {
+		instance, err := t.upstream.Pick(req, t.balancer, slices.Concat(avoid, skipped)...)
+		if err != nil {
+			if len(avoid) > 0 {
+				avoid = nil // 没有其他实例可选时，允许使用已尝试过的实例
+				continue
+			}
+			if len(skipped) > 0 {
+				return nil, nil, t.breakers.openError() // 所有可用实例均已熔断，快速失败
+			}
+			return nil, nil, err
+		}
+		if t.breakers == nil {
+			return instance, nil, nil
+		}
+		cb, ok := t.breakers.allow(instance.Addr())
+		if !ok {
+			skipped = append(skipped, instance)
+			continue
+		}
+		return instance, cb, nil
+	}
+}
+
+// send 向选定的实例发送请求，并记录熔断器结果与响应延迟
+func (t *upstreamTransport) send(req *http.Request, instance *balancer.Instance, cb *circuitbreaker.CircuitBreaker) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.reverseProxy.RoundTrip(req, t.upstream, instance)
+	if cb != nil {
+		record(cb, req, resp, err)
+	}
+	if err == nil && t.hedge != nil {
+		t.hedge.latency.observe(time.Since(start))
+	}
+	return resp, err
+}
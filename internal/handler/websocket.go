@@ -0,0 +1,474 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"api-gateway/internal/config"
+	"api-gateway/internal/metrics"
+	"go.uber.org/zap"
+)
+
+// WebSocket 连接默认参数
+const (
+	defaultWebSocketIdleTimeout = 5 * time.Minute
+	webSocketCloseGrace         = 5 * time.Second        // 发送关闭帧后等待双方关闭连接的时间
+	webSocketShutdownPoll       = 500 * time.Millisecond // 停机时检查连接是否全部关闭的间隔
+	webSocketCloseGoingAway     = 1001                   // 关闭状态码：服务端停机或连接到期
+)
+
+// 连接关闭原因
+const (
+	closeReasonClosed      = "closed" // 客户端或后端关闭
+	closeReasonIdleTimeout = "idle_timeout"
+	closeReasonMaxLifetime = "max_lifetime"
+	closeReasonShutdown    = "shutdown"
+)
+
+// WebSockets 管理经网关升级的 WebSocket 连接：按路由限制连接数，停机时通知客户端关闭
+type WebSockets struct {
+	conns   map[*webSocketConn]struct{}
+	pending map[string]int // 路由 -> 正在握手或已升级的连接数
+	closing bool
+	mu      sync.Mutex
+	metrics *metrics.WebSocketMetrics
+	logger  *zap.Logger
+}
+
+// NewWebSockets 创建 WebSockets
+func NewWebSockets(wsMetrics *metrics.WebSocketMetrics, logger *zap.Logger) *WebSockets {
+	return &WebSockets{
+		conns:   make(map[*webSocketConn]struct{}),
+		pending: make(map[string]int),
+		metrics: wsMetrics,
+		logger:  logger,
+	}
+}
+
+// Route 创建路由的 WebSocket 策略，未启用时返回 nil
+func (ws *WebSockets) Route(route string, cfg config.WebSocketConfig) *RouteWebSocket {
+	if !cfg.Enabled {
+		return nil
+	}
+	idleTimeout := cfg.IdleTimeout
+	if idleTimeout <= 0 {
+		idleTimeout = defaultWebSocketIdleTimeout
+	}
+	return &RouteWebSocket{
+		route:          route,
+		idleTimeout:    idleTimeout,
+		maxLifetime:    cfg.MaxLifetime,
+		allowedOrigins: cfg.AllowedOrigins,
+		maxConnections: cfg.MaxConnections,
+		registry:       ws,
+	}
+}
+
+// Shutdown 拒绝新的升级请求，向所有连接发送关闭帧并等待连接关闭；ctx 结束时强制关闭剩余连接
+func (ws *WebSockets) Shutdown(ctx context.Context) error {
+	ws.mu.Lock()
+	ws.closing = true
+	conns := make([]*webSocketConn, 0, len(ws.conns))
+	for c := range ws.conns {
+		conns = append(conns, c)
+	}
+	ws.mu.Unlock()
+
+	if len(conns) > 0 {
+		ws.logger.Info("通知 WebSocket 连接关闭", zap.Int("connection_count", len(conns)))
+	}
+	for _, c := range conns {
+		go c.goingAway(closeReasonShutdown) // 客户端不读取时写入会阻塞，不等待
+	}
+
+	ticker := time.NewTicker(webSocketShutdownPoll)
+	defer ticker.Stop()
+	for {
+		ws.mu.Lock()
+		remaining := make([]*webSocketConn, 0, len(ws.conns))
+		for c := range ws.conns {
+			remaining = append(remaining, c)
+		}
+		ws.mu.Unlock()
+		if len(remaining) == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			ws.logger.Warn("WebSocket 连接未在停机期限内关闭，强制关闭", zap.Int("connection_count", len(remaining)))
+			for _, c := range remaining {
+				c.Close()
+			}
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// acquire 占用路由的一个连接名额，返回拒绝原因
+func (ws *WebSockets) acquire(route string, limit int) (string, bool) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.closing {
+		return "rejected_shutdown", false
+	}
+	if limit > 0 && ws.pending[route] >= limit {
+		return "rejected_limit", false
+	}
+	ws.pending[route]++
+	return "", true
+}
+
+// release 归还路由的连接名额
+func (ws *WebSockets) release(route string) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.pending[route]--; ws.pending[route] <= 0 {
+		delete(ws.pending, route)
+	}
+}
+
+// track 登记升级成功的连接，停机期间立即通知其关闭
+func (ws *WebSockets) track(c *webSocketConn) {
+	ws.mu.Lock()
+	closing := ws.closing
+	ws.conns[c] = struct{}{}
+	ws.mu.Unlock()
+	ws.metrics.ConnectionOpened(c.route)
+	if closing {
+		go c.goingAway(closeReasonShutdown)
+	}
+}
+
+// untrack 移除已关闭的连接
+func (ws *WebSockets) untrack(c *webSocketConn, reason string) {
+	ws.mu.Lock()
+	delete(ws.conns, c)
+	ws.mu.Unlock()
+	ws.metrics.ConnectionClosed(c.route, reason, time.Since(c.opened))
+}
+
+// RouteWebSocket 单个路由的 WebSocket 策略
+type RouteWebSocket struct {
+	route          string
+	idleTimeout    time.Duration
+	maxLifetime    time.Duration
+	allowedOrigins []string
+	maxConnections int
+	registry       *WebSockets
+}
+
+// serve 检查 Origin 与连接数后交给 next 完成握手与转发；握手须在 handshakeTimeout 内完成，
+// 升级成功后连接不受请求超时限制，next 在连接关闭后返回
+func (rw *RouteWebSocket) serve(w http.ResponseWriter, r *http.Request, handshakeTimeout time.Duration, next http.HandlerFunc) {
+	ws := rw.registry
+	if !rw.originAllowed(r.Header.Get("Origin")) {
+		ws.metrics.ObserveUpgrade(rw.route, "rejected_origin")
+		http.Error(w, "不允许的 Origin", http.StatusForbidden)
+		return
+	}
+	if reason, ok := ws.acquire(rw.route, rw.maxConnections); !ok {
+		ws.metrics.ObserveUpgrade(rw.route, reason)
+		http.Error(w, "WebSocket 连接数已达上限", http.StatusServiceUnavailable)
+		return
+	}
+	defer ws.release(rw.route)
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	handshake := time.AfterFunc(handshakeTimeout, cancel) // 升级成功后停止计时
+	defer handshake.Stop()
+
+	ww := &webSocketWriter{ResponseWriter: w, route: rw, handshake: handshake}
+	next(ww, r.WithContext(ctx))
+	if ww.conn == nil { // 后端没有同意升级，响应已按普通请求转发
+		ws.metrics.ObserveUpgrade(rw.route, "failed")
+	}
+}
+
+// originAllowed 判断握手请求的 Origin 是否在允许列表中
+func (rw *RouteWebSocket) originAllowed(origin string) bool {
+	if len(rw.allowedOrigins) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(rw.allowedOrigins, func(allowed string) bool {
+		return allowed == "*" || strings.EqualFold(allowed, origin)
+	})
+}
+
+// isWebSocketUpgrade 判断请求是否为 WebSocket 升级请求
+func isWebSocketUpgrade(r *http.Request) bool {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return false
+	}
+	for _, value := range r.Header.Values("Connection") {
+		for _, token := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// webSocketWriter 在反向代理劫持连接时接管客户端连接
+type webSocketWriter struct {
+	http.ResponseWriter
+	route     *RouteWebSocket
+	handshake *time.Timer
+	conn      *webSocketConn
+}
+
+// Hijack 劫持客户端连接：清除 http.Server 设置的读写超时，改由空闲与最长存活时间控制
+func (ww *webSocketWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(ww.ResponseWriter).Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	ww.handshake.Stop()
+	_ = conn.SetDeadline(time.Time{})
+
+	rw := ww.route
+	c := &webSocketConn{Conn: conn, route: rw.route, registry: rw.registry, opened: time.Now(), done: make(chan struct{})}
+	c.touch()
+	ww.conn = c
+	rw.registry.metrics.ObserveUpgrade(rw.route, "upgraded")
+	rw.registry.track(c)
+	go c.watch(rw.idleTimeout, rw.maxLifetime)
+	// 101 响应头也经由 c 写出，使关闭帧只会出现在响应头之后的帧边界上
+	return c, bufio.NewReadWriter(brw.Reader, bufio.NewWriter(c)), nil
+}
+
+// Flush 透传流式响应的刷新
+func (ww *webSocketWriter) Flush() {
+	if f, ok := ww.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap 返回被包装的 ResponseWriter，供 http.ResponseController 使用
+func (ww *webSocketWriter) Unwrap() http.ResponseWriter {
+	return ww.ResponseWriter
+}
+
+// webSocketConn 升级后的客户端连接，记录最近活动时间，并可在帧边界处插入关闭帧
+type webSocketConn struct {
+	net.Conn
+	route      string
+	registry   *WebSockets
+	opened     time.Time
+	lastActive atomic.Int64
+	done       chan struct{}
+	closeOnce  sync.Once
+
+	mu           sync.Mutex // 保护以下字段与向客户端的写入
+	frames       frameTracker
+	reason       string // 网关主动关闭的原因
+	closePending bool   // 当前帧写完后发送关闭帧
+	closeSent    bool   // 已发送关闭帧，不再向客户端写入数据帧
+}
+
+func (c *webSocketConn) touch() {
+	c.lastActive.Store(time.Now().UnixNano())
+}
+
+func (c *webSocketConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.touch()
+	}
+	return n, err
+}
+
+func (c *webSocketConn) Write(b []byte) (int, error) {
+	c.touch()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closeSent {
+		return len(b), nil // 关闭帧之后后端发来的帧直接丢弃
+	}
+	if !c.closePending {
+		n, err := c.Conn.Write(b)
+		c.frames.advance(b[:n], false)
+		return n, err
+	}
+	n := c.frames.advance(b, true)
+	if _, err := c.Conn.Write(b[:n]); err != nil {
+		return 0, err
+	}
+	if c.frames.atBoundary() {
+		if err := c.sendClose(); err != nil {
+			return n, err
+		}
+	}
+	return len(b), nil
+}
+
+func (c *webSocketConn) Close() error {
+	err := net.ErrClosed
+	c.closeOnce.Do(func() {
+		close(c.done)
+		err = c.Conn.Close()
+		c.mu.Lock()
+		reason := c.reason
+		c.mu.Unlock()
+		if reason == "" {
+			reason = closeReasonClosed
+		}
+		c.registry.untrack(c, reason)
+	})
+	return err
+}
+
+// watch 在连接空闲或到期时关闭连接
+func (c *webSocketConn) watch(idleTimeout, maxLifetime time.Duration) {
+	var expired <-chan time.Time
+	if maxLifetime > 0 {
+		lifetime := time.NewTimer(maxLifetime)
+		defer lifetime.Stop()
+		expired = lifetime.C
+	}
+	idle := time.NewTimer(idleTimeout)
+	defer idle.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-expired:
+			c.expire(closeReasonMaxLifetime)
+			return
+		case <-idle.C:
+			if remaining := time.Until(time.Unix(0, c.lastActive.Load()).Add(idleTimeout)); remaining > 0 {
+				idle.Reset(remaining)
+				continue
+			}
+			c.expire(closeReasonIdleTimeout)
+			return
+		}
+	}
+}
+
+// expire 发送关闭帧，并在宽限时间后强制关闭连接
+func (c *webSocketConn) expire(reason string) {
+	c.goingAway(reason)
+	select {
+	case <-c.done:
+	case <-time.After(webSocketCloseGrace):
+		c.Close()
+	}
+}
+
+// goingAway 通知客户端关闭连接：当前没有写到一半的帧时立即发送关闭帧，否则在该帧写完后发送
+func (c *webSocketConn) goingAway(reason string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.reason == "" {
+		c.reason = reason
+	}
+	if c.closeSent || c.closePending {
+		return
+	}
+	if !c.frames.atBoundary() {
+		c.closePending = true
+		return
+	}
+	if err := c.sendClose(); err != nil {
+		c.registry.logger.Debug("发送 WebSocket 关闭帧失败", zap.String("route", c.route), zap.Error(err))
+	}
+}
+
+// sendClose 向客户端发送状态码为 1001 的关闭帧，调用方须持有 c.mu
+func (c *webSocketConn) sendClose() error {
+	c.closePending, c.closeSent = false, true
+	_ = c.Conn.SetWriteDeadline(time.Now().Add(webSocketCloseGrace))
+	frame := []byte{0x88, 2, 0, 0} // FIN + close 操作码，服务端发送的帧不加掩码
+	binary.BigEndian.PutUint16(frame[2:], webSocketCloseGoingAway)
+	_, err := c.Conn.Write(frame)
+	return err
+}
+
+// frameTracker 跟踪写向客户端的字节流 (101 响应头与之后的 WebSocket 帧) 中帧的边界
+type frameTracker struct {
+	upgraded  bool   // 101 响应头已写完
+	crlf      int    // 已匹配的响应头结束符 "\r\n\r\n" 字节数
+	header    []byte // 尚未完整的帧头
+	remaining uint64 // 当前帧尚未写出的负载字节数
+}
+
+// atBoundary 是否位于响应头之后的两个帧之间
+func (t *frameTracker) atBoundary() bool {
+	return t.upgraded && len(t.header) == 0 && t.remaining == 0
+}
+
+// advance 处理写出的字节；stopAtBoundary 时在遇到的第一个帧边界处停止，返回处理的字节数
+func (t *frameTracker) advance(b []byte, stopAtBoundary bool) int {
+	n := 0
+	for n < len(b) {
+		if !t.upgraded {
+			switch {
+			case b[n] == "\r\n\r\n"[t.crlf]:
+				t.crlf++
+			case b[n] == '\r':
+				t.crlf = 1
+			default:
+				t.crlf = 0
+			}
+			n++
+			t.upgraded = t.crlf == 4
+		} else if t.remaining > 0 {
+			k := min(t.remaining, uint64(len(b)-n))
+			t.remaining -= k
+			n += int(k)
+		} else {
+			t.header = append(t.header, b[n])
+			n++
+			if size, ok := frameHeaderSize(t.header); ok && len(t.header) == size {
+				t.remaining = framePayloadLength(t.header)
+				t.header = t.header[:0]
+			}
+		}
+		if stopAtBoundary && t.atBoundary() {
+			break
+		}
+	}
+	return n
+}
+
+// frameHeaderSize 根据帧头的前两个字节计算帧头长度
+func frameHeaderSize(header []byte) (int, bool) {
+	if len(header) < 2 {
+		return 0, false
+	}
+	size := 2
+	switch header[1] & 0x7f {
+	case 126:
+		size += 2
+	case 127:
+		size += 8
+	}
+	if header[1]&0x80 != 0 { // 掩码
+		size += 4
+	}
+	return size, true
+}
+
+// framePayloadLength 从完整的帧头中读取负载长度
+func framePayloadLength(header []byte) uint64 {
+	switch n := header[1] & 0x7f; n {
+	case 126:
+		return uint64(binary.BigEndian.Uint16(header[2:4]))
+	case 127:
+		return binary.BigEndian.Uint64(header[2:10])
+	default:
+		return uint64(n)
+	}
+}
@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// BackendMetrics 按路由后端 (如 stable、canary) 区分的请求指标，用于对比流量拆分的各个后端
+type BackendMetrics struct {
+	requestsTotal  *prometheus.CounterVec
+	requestLatency *prometheus.HistogramVec
+}
+
+// NewBackendMetrics 创建 BackendMetrics
+func NewBackendMetrics() *BackendMetrics {
+	requestsTotal := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "api_gateway_backend_requests_total",
+		Help: "Total requests routed to each route backend.",
+	}, []string{"route", "backend", "status_code"})
+
+	requestLatency := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "api_gateway_backend_request_latency_seconds",
+		Help:    "Request latency in seconds for each route backend.",
+		Buckets: []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2, 5, 10},
+	}, []string{"route", "backend"})
+
+	prometheus.MustRegister(requestsTotal, requestLatency)
+
+	return &BackendMetrics{
+		requestsTotal:  requestsTotal,
+		requestLatency: requestLatency,
+	}
+}
+
+// Observe 记录一次路由到 backend 的请求
+func (m *BackendMetrics) Observe(route, backend string, statusCode int, duration time.Duration) {
+	m.requestsTotal.WithLabelValues(route, backend, strconv.Itoa(statusCode)).Inc()
+	m.requestLatency.WithLabelValues(route, backend).Observe(duration.Seconds())
+}
@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// CircuitBreakerMetrics 熔断器相关指标
+type CircuitBreakerMetrics struct {
+	state         *prometheus.GaugeVec
+	transitions   *prometheus.CounterVec
+	rejectedTotal *prometheus.CounterVec
+}
+
+// NewCircuitBreakerMetrics 创建 CircuitBreakerMetrics
+func NewCircuitBreakerMetrics() *CircuitBreakerMetrics {
+	state := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "api_gateway_circuit_breaker_state",
+		Help: "Current circuit breaker state per route and upstream host (0 closed, 1 open, 2 half-open).",
+	}, []string{"route", "host"})
+
+	transitions := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "api_gateway_circuit_breaker_transitions_total",
+		Help: "Total circuit breaker state transitions.",
+	}, []string{"route", "host", "from", "to"})
+
+	rejectedTotal := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "api_gateway_circuit_breaker_rejected_total",
+		Help: "Total requests rejected by an open circuit breaker.",
+	}, []string{"route", "host"})
+
+	prometheus.MustRegister(state, transitions, rejectedTotal)
+
+	return &CircuitBreakerMetrics{
+		state:         state,
+		transitions:   transitions,
+		rejectedTotal: rejectedTotal,
+	}
+}
+
+// ObserveTransition 记录一次状态变化，state 为变化后状态的数值
+func (m *CircuitBreakerMetrics) ObserveTransition(route, host, from, to string, state int) {
+	m.transitions.WithLabelValues(route, host, from, to).Inc()
+	m.state.WithLabelValues(route, host).Set(float64(state))
+}
+
+// SetState 设置熔断器当前状态的数值
+func (m *CircuitBreakerMetrics) SetState(route, host string, state int) {
+	m.state.WithLabelValues(route, host).Set(float64(state))
+}
+
+// ObserveRejected 记录一次被熔断拒绝的请求
+func (m *CircuitBreakerMetrics) ObserveRejected(route, host string) {
+	m.rejectedTotal.WithLabelValues(route, host).Inc()
+}
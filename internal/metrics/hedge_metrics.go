@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// HedgeMetrics 对冲请求相关指标
+type HedgeMetrics struct {
+	hedgesTotal *prometheus.CounterVec
+	winsTotal   *prometheus.CounterVec
+}
+
+// NewHedgeMetrics 创建 HedgeMetrics
+func NewHedgeMetrics() *HedgeMetrics {
+	hedgesTotal := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "api_gateway_hedge_requests_total",
+		Help: "Total hedged requests sent after the hedge delay elapsed.",
+	}, []string{"route"})
+
+	winsTotal := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "api_gateway_hedge_wins_total",
+		Help: "Total hedged requests whose response arrived before the original request's.",
+	}, []string{"route"})
+
+	prometheus.MustRegister(hedgesTotal, winsTotal)
+
+	return &HedgeMetrics{
+		hedgesTotal: hedgesTotal,
+		winsTotal:   winsTotal,
+	}
+}
+
+// ObserveHedge 记录一次对冲请求
+func (m *HedgeMetrics) ObserveHedge(route string) {
+	m.hedgesTotal.WithLabelValues(route).Inc()
+}
+
+// ObserveWin 记录一次对冲请求胜出
+func (m *HedgeMetrics) ObserveWin(route string) {
+	m.winsTotal.WithLabelValues(route).Inc()
+}
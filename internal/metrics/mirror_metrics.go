@@ -0,0 +1,76 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// MirrorMetrics 流量镜像相关指标
+type MirrorMetrics struct {
+	requestsTotal *prometheus.CounterVec
+	skippedTotal  *prometheus.CounterVec
+	mismatchTotal *prometheus.CounterVec
+	latencyDelta  *prometheus.HistogramVec
+	mirrorLatency *prometheus.HistogramVec
+}
+
+// NewMirrorMetrics 创建 MirrorMetrics
+func NewMirrorMetrics() *MirrorMetrics {
+	requestsTotal := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "api_gateway_mirror_requests_total",
+		Help: "Total mirrored requests sent to shadow backends, by shadow response status code (\"error\" when no response was received).",
+	}, []string{"route", "status_code"})
+
+	skippedTotal := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "api_gateway_mirror_skipped_total",
+		Help: "Total sampled requests that were not mirrored.",
+	}, []string{"route", "reason"})
+
+	mismatchTotal := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "api_gateway_mirror_status_mismatch_total",
+		Help: "Total mirrored requests whose shadow status code differed from the primary response.",
+	}, []string{"route"})
+
+	latencyDelta := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "api_gateway_mirror_latency_delta_seconds",
+		Help:    "Shadow backend latency minus primary backend latency in seconds.",
+		Buckets: []float64{-1, -0.25, -0.1, -0.05, -0.01, 0, 0.01, 0.05, 0.1, 0.25, 1},
+	}, []string{"route"})
+
+	mirrorLatency := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "api_gateway_mirror_latency_seconds",
+		Help:    "Shadow backend latency in seconds.",
+		Buckets: []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2, 5, 10},
+	}, []string{"route"})
+
+	prometheus.MustRegister(requestsTotal, skippedTotal, mismatchTotal, latencyDelta, mirrorLatency)
+
+	return &MirrorMetrics{
+		requestsTotal: requestsTotal,
+		skippedTotal:  skippedTotal,
+		mismatchTotal: mismatchTotal,
+		latencyDelta:  latencyDelta,
+		mirrorLatency: mirrorLatency,
+	}
+}
+
+// ObserveSkipped 记录一次因 reason 未能镜像的请求
+func (m *MirrorMetrics) ObserveSkipped(route, reason string) {
+	m.skippedTotal.WithLabelValues(route, reason).Inc()
+}
+
+// ObserveMirror 记录一次镜像请求与主请求的对比结果，mirrorStatus 为 0 表示镜像请求没有收到响应
+func (m *MirrorMetrics) ObserveMirror(route string, mirrorStatus, primaryStatus int, mirrorLatency, primaryLatency time.Duration) {
+	status := "error"
+	if mirrorStatus != 0 {
+		status = strconv.Itoa(mirrorStatus)
+	}
+	m.requestsTotal.WithLabelValues(route, status).Inc()
+	if mirrorStatus != primaryStatus {
+		m.mismatchTotal.WithLabelValues(route).Inc()
+	}
+	m.mirrorLatency.WithLabelValues(route).Observe(mirrorLatency.Seconds())
+	m.latencyDelta.WithLabelValues(route).Observe((mirrorLatency - primaryLatency).Seconds())
+}
@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// TLSMetrics HTTPS 证书相关指标
+type TLSMetrics struct {
+	certificateExpiry *prometheus.GaugeVec
+	reloadsTotal      *prometheus.CounterVec
+}
+
+// NewTLSMetrics 创建 TLSMetrics
+func NewTLSMetrics() *TLSMetrics {
+	certificateExpiry := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "api_gateway_tls_certificate_expiry_timestamp_seconds",
+		Help: "Expiry time (NotAfter) of the loaded TLS certificates as a Unix timestamp.",
+	}, []string{"certificate"})
+
+	reloadsTotal := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "api_gateway_tls_certificate_reloads_total",
+		Help: "Total TLS certificate reloads, by result (success, failure).",
+	}, []string{"result"})
+
+	prometheus.MustRegister(certificateExpiry, reloadsTotal)
+
+	return &TLSMetrics{
+		certificateExpiry: certificateExpiry,
+		reloadsTotal:      reloadsTotal,
+	}
+}
+
+// SetCertificates 记录当前生效的证书 (按证书文件) 的过期时间，替换之前记录的证书
+func (m *TLSMetrics) SetCertificates(expiry map[string]time.Time) {
+	m.certificateExpiry.Reset()
+	for certFile, notAfter := range expiry {
+		m.certificateExpiry.WithLabelValues(certFile).Set(float64(notAfter.Unix()))
+	}
+}
+
+// ObserveReload 记录一次证书重新加载的结果
+func (m *TLSMetrics) ObserveReload(result string) {
+	m.reloadsTotal.WithLabelValues(result).Inc()
+}
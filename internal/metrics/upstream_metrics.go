@@ -0,0 +1,94 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// UpstreamMetrics 后端实例相关指标
+type UpstreamMetrics struct {
+	instanceHealthy  *prometheus.GaugeVec
+	healthCheckTotal *prometheus.CounterVec
+	instanceEjected  *prometheus.GaugeVec
+	ejectionsTotal   *prometheus.CounterVec
+	localityTotal    *prometheus.CounterVec
+}
+
+// NewUpstreamMetrics 创建 UpstreamMetrics
+func NewUpstreamMetrics() *UpstreamMetrics {
+	instanceHealthy := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "api_gateway_upstream_instance_healthy",
+		Help: "Whether an upstream instance passes active health checks (1 healthy, 0 unhealthy).",
+	}, []string{"upstream", "instance"})
+
+	healthCheckTotal := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "api_gateway_upstream_health_checks_total",
+		Help: "Total active health checks performed against upstream instances.",
+	}, []string{"upstream", "result"})
+
+	instanceEjected := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "api_gateway_upstream_instance_ejected",
+		Help: "Whether an upstream instance is currently ejected by outlier detection (1 ejected, 0 not ejected).",
+	}, []string{"upstream", "instance"})
+
+	ejectionsTotal := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "api_gateway_upstream_outlier_ejections_total",
+		Help: "Total outlier ejections of upstream instances.",
+	}, []string{"upstream", "reason"})
+
+	localityTotal := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "api_gateway_upstream_locality_picks_total",
+		Help: "Total locality-aware instance picks by the locality of the chosen instance (zone, region, remote); region and remote indicate spillover.",
+	}, []string{"upstream", "locality"})
+
+	prometheus.MustRegister(instanceHealthy, healthCheckTotal, instanceEjected, ejectionsTotal, localityTotal)
+
+	return &UpstreamMetrics{
+		instanceHealthy:  instanceHealthy,
+		healthCheckTotal: healthCheckTotal,
+		instanceEjected:  instanceEjected,
+		ejectionsTotal:   ejectionsTotal,
+		localityTotal:    localityTotal,
+	}
+}
+
+// SetInstanceHealthy 记录实例的健康状态
+func (m *UpstreamMetrics) SetInstanceHealthy(upstream, instance string, healthy bool) {
+	value := 0.0
+	if healthy {
+		value = 1
+	}
+	m.instanceHealthy.WithLabelValues(upstream, instance).Set(value)
+}
+
+// DeleteInstance 删除已下线实例的健康状态指标
+func (m *UpstreamMetrics) DeleteInstance(upstream, instance string) {
+	m.instanceHealthy.DeleteLabelValues(upstream, instance)
+}
+
+// ObserveHealthCheck 记录一次健康检查结果
+func (m *UpstreamMetrics) ObserveHealthCheck(upstream string, success bool) {
+	result := "success"
+	if !success {
+		result = "failure"
+	}
+	m.healthCheckTotal.WithLabelValues(upstream, result).Inc()
+}
+
+// SetInstanceEjected 记录实例是否处于弹出状态
+func (m *UpstreamMetrics) SetInstanceEjected(upstream, instance string, ejected bool) {
+	value := 0.0
+	if ejected {
+		value = 1
+	}
+	m.instanceEjected.WithLabelValues(upstream, instance).Set(value)
+}
+
+// ObserveEjection 记录一次异常实例弹出
+func (m *UpstreamMetrics) ObserveEjection(upstream, reason string) {
+	m.ejectionsTotal.WithLabelValues(upstream, reason).Inc()
+}
+
+// ObserveLocality 记录一次就近路由选择的实例位置
+func (m *UpstreamMetrics) ObserveLocality(upstream, locality string) {
+	m.localityTotal.WithLabelValues(upstream, locality).Inc()
+}
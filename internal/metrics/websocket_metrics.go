@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// WebSocketMetrics WebSocket 连接相关指标
+type WebSocketMetrics struct {
+	upgradesTotal      *prometheus.CounterVec
+	activeConnections  *prometheus.GaugeVec
+	closedTotal        *prometheus.CounterVec
+	connectionDuration *prometheus.HistogramVec
+}
+
+// NewWebSocketMetrics 创建 WebSocketMetrics
+func NewWebSocketMetrics() *WebSocketMetrics {
+	upgradesTotal := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "api_gateway_websocket_upgrades_total",
+		Help: "Total WebSocket upgrade requests, by result (upgraded, rejected_origin, rejected_limit, rejected_shutdown, failed).",
+	}, []string{"route", "result"})
+
+	activeConnections := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "api_gateway_websocket_connections_active",
+		Help: "Current number of upgraded WebSocket connections.",
+	}, []string{"route"})
+
+	closedTotal := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "api_gateway_websocket_connections_closed_total",
+		Help: "Total closed WebSocket connections, by reason (closed, idle_timeout, max_lifetime, shutdown).",
+	}, []string{"route", "reason"})
+
+	connectionDuration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "api_gateway_websocket_connection_duration_seconds",
+		Help:    "WebSocket connection lifetime in seconds.",
+		Buckets: []float64{1, 5, 30, 60, 300, 900, 1800, 3600, 7200, 14400},
+	}, []string{"route"})
+
+	prometheus.MustRegister(upgradesTotal, activeConnections, closedTotal, connectionDuration)
+
+	return &WebSocketMetrics{
+		upgradesTotal:      upgradesTotal,
+		activeConnections:  activeConnections,
+		closedTotal:        closedTotal,
+		connectionDuration: connectionDuration,
+	}
+}
+
+// ObserveUpgrade 记录一次升级请求的结果
+func (m *WebSocketMetrics) ObserveUpgrade(route, result string) {
+	m.upgradesTotal.WithLabelValues(route, result).Inc()
+}
+
+// ConnectionOpened 记录一个升级成功的连接
+func (m *WebSocketMetrics) ConnectionOpened(route string) {
+	m.activeConnections.WithLabelValues(route).Inc()
+}
+
+// ConnectionClosed 记录一个因 reason 关闭的连接及其存活时间
+func (m *WebSocketMetrics) ConnectionClosed(route, reason string, lifetime time.Duration) {
+	m.activeConnections.WithLabelValues(route).Dec()
+	m.closedTotal.WithLabelValues(route, reason).Inc()
+	m.connectionDuration.WithLabelValues(route).Observe(lifetime.Seconds())
+}
@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"api-gateway/internal/config"
+)
+
+// defaultCORSMethods 未配置 allow_methods 时允许的方法
+var defaultCORSMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions}
+
+// grpcWebAllowHeaders 与 grpcWebExposeHeaders gRPC-Web 客户端发送与读取的请求头、响应头
+var (
+	grpcWebAllowHeaders  = []string{"Content-Type", "X-Grpc-Web", "X-User-Agent", "Grpc-Timeout"}
+	grpcWebExposeHeaders = []string{"Grpc-Status", "Grpc-Message", "Grpc-Status-Details-Bin"}
+)
+
+// WithGRPCWebHeaders 返回补充了 gRPC-Web 所需请求头与响应头的跨域配置
+func WithGRPCWebHeaders(corsConfig config.CORSConfig) config.CORSConfig {
+	if len(corsConfig.AllowHeaders) > 0 { // 为空时已允许预检请求中声明的所有请求头
+		corsConfig.AllowHeaders = appendMissingHeaders(corsConfig.AllowHeaders, grpcWebAllowHeaders)
+	}
+	corsConfig.ExposeHeaders = appendMissingHeaders(corsConfig.ExposeHeaders, grpcWebExposeHeaders)
+	return corsConfig
+}
+
+// appendMissingHeaders 将 headers 中尚未包含的请求头 (不区分大小写) 追加到 list 的副本中
+func appendMissingHeaders(list, headers []string) []string {
+	list = slices.Clip(list)
+	for _, header := range headers {
+		if !slices.ContainsFunc(list, func(h string) bool { return strings.EqualFold(h, header) }) {
+			list = append(list, header)
+		}
+	}
+	return list
+}
+
+// CORSMiddleware 跨域中间件：为允许的来源添加 CORS 响应头，并直接响应预检请求
+func CORSMiddleware(corsConfig config.CORSConfig) func(http.Handler) http.Handler {
+	if !corsConfig.Enabled {
+		return func(next http.Handler) http.Handler {
+			return next // 如果未启用跨域，则直接放行
+		}
+	}
+
+	methods := corsConfig.AllowMethods
+	if len(methods) == 0 {
+		methods = defaultCORSMethods
+	}
+	allowMethods := strings.ToUpper(strings.Join(methods, ", "))
+	allowHeaders := strings.Join(corsConfig.AllowHeaders, ", ")
+	exposeHeaders := strings.Join(corsConfig.ExposeHeaders, ", ")
+	anyOrigin := slices.Contains(corsConfig.AllowOrigins, "*")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" || !anyOrigin && !slices.Contains(corsConfig.AllowOrigins, origin) {
+				next.ServeHTTP(w, r) // 非跨域请求或来源不在允许列表中，不添加 CORS 响应头
+				return
+			}
+
+			h := w.Header()
+			h.Add("Vary", "Origin")
+			if anyOrigin && !corsConfig.AllowCredentials {
+				h.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				h.Set("Access-Control-Allow-Origin", origin) // 携带凭证时不能使用 "*"
+			}
+			if corsConfig.AllowCredentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			// 预检请求由网关直接响应，不转发给后端
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				h.Set("Access-Control-Allow-Methods", allowMethods)
+				if allowHeaders != "" {
+					h.Set("Access-Control-Allow-Headers", allowHeaders)
+				} else if requested := r.Header.Get("Access-Control-Request-Headers"); requested != "" {
+					h.Set("Access-Control-Allow-Headers", requested)
+				}
+				if corsConfig.MaxAge > 0 {
+					h.Set("Access-Control-Max-Age", strconv.Itoa(int(corsConfig.MaxAge.Seconds())))
+				}
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			if exposeHeaders != "" {
+				h.Set("Access-Control-Expose-Headers", exposeHeaders)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+
+	"api-gateway/internal/config"
+)
+
+// HeadersMiddleware 请求头与响应头转换中间件
+func HeadersMiddleware(headersConfig config.HeadersConfig) func(http.Handler) http.Handler {
+	if !headersConfig.Enabled {
+		return func(next http.Handler) http.Handler {
+			return next // 如果未启用请求头转换，则直接放行
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, name := range headersConfig.RequestRemove {
+				r.Header.Del(name)
+			}
+			for name, value := range headersConfig.RequestSet {
+				r.Header.Set(name, value)
+			}
+			if len(headersConfig.ResponseSet) > 0 || len(headersConfig.ResponseRemove) > 0 {
+				w = &headerRewriteWriter{ResponseWriter: w, cfg: headersConfig}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// headerRewriteWriter 在写出响应头前转换后端返回的响应头
+type headerRewriteWriter struct {
+	http.ResponseWriter
+	cfg         config.HeadersConfig
+	wroteHeader bool
+}
+
+func (hw *headerRewriteWriter) WriteHeader(code int) {
+	if !hw.wroteHeader && code >= http.StatusOK { // 1xx 信息响应之后还会写出最终响应头
+		hw.wroteHeader = true
+		h := hw.ResponseWriter.Header()
+		for _, name := range hw.cfg.ResponseRemove {
+			h.Del(name)
+		}
+		for name, value := range hw.cfg.ResponseSet {
+			h.Set(name, value)
+		}
+	}
+	hw.ResponseWriter.WriteHeader(code)
+}
+
+func (hw *headerRewriteWriter) Write(b []byte) (int, error) {
+	if !hw.wroteHeader {
+		hw.WriteHeader(http.StatusOK)
+	}
+	return hw.ResponseWriter.Write(b)
+}
+
+// Hijack 透传连接劫持，协议升级的响应头由劫持方直接写出，不做转换
+func (hw *headerRewriteWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(hw.ResponseWriter).Hijack()
+	if err == nil {
+		hw.wroteHeader = true
+	}
+	return conn, brw, err
+}
+
+// Flush 透传流式响应的刷新
+func (hw *headerRewriteWriter) Flush() {
+	if !hw.wroteHeader {
+		hw.WriteHeader(http.StatusOK)
+	}
+	if f, ok := hw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap 返回被包装的 ResponseWriter，供 http.ResponseController 使用
+func (hw *headerRewriteWriter) Unwrap() http.ResponseWriter {
+	return hw.ResponseWriter
+}
@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"api-gateway/internal/config"
+	"api-gateway/pkg/ratelimiter"
+	"go.uber.org/zap"
+)
+
+// PolicyChain 根据路由策略构建中间件链，执行顺序：链路追踪 → 跨域 → 认证 → 限流 → 请求头转换；
+// rateLimiter 为路由所属作用域的令牌桶 (见 RateLimiters)，未启用限流时为 nil
+func PolicyChain(policies config.PolicyConfig, rateLimiter *ratelimiter.TokenBucketLimiter, shutdownTracer func(ctx context.Context) error, logger *zap.Logger) func(http.Handler) http.Handler {
+	var chain []func(http.Handler) http.Handler
+	if policies.Tracing == nil || policies.Tracing.Enabled {
+		chain = append(chain, TracingMiddleware(shutdownTracer))
+	}
+	if policies.CORS != nil {
+		chain = append(chain, CORSMiddleware(*policies.CORS))
+	}
+	if policies.Auth != nil {
+		auth := *policies.Auth
+		getAuthConfig := func() config.AuthConfig { return auth }
+		chain = append(chain, AuthMiddleware(getAuthConfig, logger), OAuth2Middleware(getAuthConfig, logger))
+	}
+	if rateLimiter != nil {
+		chain = append(chain, RateLimiterMiddleware(rateLimiter, logger))
+	}
+	if policies.Headers != nil {
+		chain = append(chain, HeadersMiddleware(*policies.Headers))
+	}
+
+	return func(next http.Handler) http.Handler {
+		for i := len(chain) - 1; i >= 0; i-- { // 倒序包装，保证执行顺序
+			next = chain[i](next)
+		}
+		return next
+	}
+}
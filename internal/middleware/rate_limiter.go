@@ -2,25 +2,49 @@ package middleware
 
 import (
 	"net/http"
+	"sync"
 
 	"api-gateway/internal/config"
 	"api-gateway/pkg/ratelimiter"
 	"go.uber.org/zap"
 )
 
-// RateLimiterMiddleware 限流中间件
-func RateLimiterMiddleware(rateLimitConfig config.RateLimitConfig, logger *zap.Logger) func(http.Handler) http.Handler {
-	if !rateLimitConfig.Enabled {
-		return func(next http.Handler) http.Handler {
-			return next // 如果未启用限流，则直接放行
-		}
-	}
+// RateLimiters 管理限流令牌桶：同一作用域 (全局默认、虚拟主机或单个路由) 的路由共用一个令牌桶，配置未变化时跨配置重载保留
+type RateLimiters struct {
+	scopes map[string]*scopedLimiter
+	mu     sync.Mutex
+}
+
+// scopedLimiter 一个作用域的令牌桶及其配置
+type scopedLimiter struct {
+	cfg     config.RateLimitConfig
+	limiter *ratelimiter.TokenBucketLimiter
+}
+
+// NewRateLimiters 创建 RateLimiters
+func NewRateLimiters() *RateLimiters {
+	return &RateLimiters{scopes: make(map[string]*scopedLimiter)}
+}
+
+// Get 获取作用域的令牌桶，未配置或未启用限流时返回 nil；配置变化时重新创建
+func (l *RateLimiters) Get(scope string, cfg *config.RateLimitConfig) *ratelimiter.TokenBucketLimiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 
-	limiter := ratelimiter.NewTokenBucketLimiter(
-		rateLimitConfig.Requests,
-		rateLimitConfig.Interval,
-	)
+	if cfg == nil || !cfg.Enabled {
+		delete(l.scopes, scope)
+		return nil
+	}
+	if s, ok := l.scopes[scope]; ok && s.cfg == *cfg {
+		return s.limiter
+	}
+	s := &scopedLimiter{cfg: *cfg, limiter: ratelimiter.NewTokenBucketLimiter(cfg.Requests, cfg.Interval)}
+	l.scopes[scope] = s
+	return s.limiter
+}
 
+// RateLimiterMiddleware 限流中间件，limiter 可被多个路由共用
+func RateLimiterMiddleware(limiter *ratelimiter.TokenBucketLimiter, logger *zap.Logger) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			if !limiter.Allow() {
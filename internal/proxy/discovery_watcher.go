@@ -0,0 +1,81 @@
+package proxy
+
+import (
+	"context"
+	"sync"
+
+	"api-gateway/internal/service/consul"
+	"go.uber.org/zap"
+)
+
+// DiscoveryWatcher 监听服务发现中的实例变化，并实时同步到对应的 Upstream
+type DiscoveryWatcher struct {
+	reverseProxy *ReverseProxy
+	discovery    consul.ServiceDiscovery
+	watches      map[string]context.CancelFunc // 服务名 -> 取消监听
+	subsets      map[string][]ServiceSubset    // 服务名 -> 需要同步的子集
+	mu           sync.Mutex
+	logger       *zap.Logger
+}
+
+// NewDiscoveryWatcher 创建 DiscoveryWatcher
+func NewDiscoveryWatcher(reverseProxy *ReverseProxy, discovery consul.ServiceDiscovery, logger *zap.Logger) *DiscoveryWatcher {
+	return &DiscoveryWatcher{
+		reverseProxy: reverseProxy,
+		discovery:    discovery,
+		watches:      make(map[string]context.CancelFunc),
+		subsets:      make(map[string][]ServiceSubset),
+		logger:       logger,
+	}
+}
+
+// Sync 使监听的服务集合与 subsets 一致：每个服务只监听一次，实例变化时同步到该服务的所有子集；停止已不再使用的服务的监听
+func (w *DiscoveryWatcher) Sync(subsets []ServiceSubset) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	wanted := make(map[string][]ServiceSubset)
+	for _, subset := range subsets {
+		wanted[subset.ServiceName] = append(wanted[subset.ServiceName], subset)
+	}
+	w.subsets = wanted
+
+	for name := range wanted {
+		if _, ok := w.watches[name]; ok {
+			continue
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		w.watches[name] = cancel
+		go w.watch(ctx, name)
+		w.logger.Info("开始监听服务实例变化", zap.String("service_name", name))
+	}
+
+	for name, cancel := range w.watches {
+		if _, ok := wanted[name]; !ok {
+			cancel()
+			delete(w.watches, name)
+			w.logger.Info("停止监听服务实例变化", zap.String("service_name", name))
+		}
+	}
+}
+
+// Stop 停止所有监听
+func (w *DiscoveryWatcher) Stop() {
+	w.Sync(nil)
+}
+
+// watch 监听单个服务，直到 ctx 被取消
+func (w *DiscoveryWatcher) watch(ctx context.Context, serviceName string) {
+	err := w.discovery.WatchServiceInstances(ctx, serviceName, func(serviceInstances []*consul.ServiceInstance) {
+		w.mu.Lock()
+		subsets := w.subsets[serviceName]
+		w.mu.Unlock()
+		for _, subset := range subsets {
+			w.reverseProxy.SetServiceInstances(subset, serviceInstances)
+		}
+		w.logger.Info("服务实例发生变化", zap.String("service_name", serviceName), zap.Int("instance_count", len(serviceInstances)), zap.Int("subset_count", len(subsets)))
+	})
+	if err != nil && ctx.Err() == nil {
+		w.logger.Error("监听服务实例失败", zap.String("service_name", serviceName), zap.Error(err))
+	}
+}
@@ -0,0 +1,40 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"api-gateway/pkg/grpcstatus"
+)
+
+// IsGRPCRequest 判断请求是否为 gRPC 请求 (Content-Type 为 application/grpc 或 application/grpc+xxx)
+func IsGRPCRequest(r *http.Request) bool {
+	contentType := r.Header.Get("Content-Type")
+	return contentType == "application/grpc" || strings.HasPrefix(contentType, "application/grpc+") || strings.HasPrefix(contentType, "application/grpc;")
+}
+
+// newGRPCTransport 创建转发 gRPC 请求的 Transport：gRPC 只能使用 HTTP/2，明文实例使用 h2c (prior knowledge)，TLS 实例通过 ALPN 协商
+func newGRPCTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Protocols = new(http.Protocols)
+	t.Protocols.SetHTTP2(true)
+	t.Protocols.SetUnencryptedHTTP2(true)
+	return t
+}
+
+// grpcErrorCode 将转发失败的原因映射为 gRPC 状态码
+func grpcErrorCode(err error) grpcstatus.Code {
+	var failFast *FailFastError
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return grpcstatus.DeadlineExceeded
+	case errors.Is(err, context.Canceled):
+		return grpcstatus.Canceled
+	case errors.As(err, &failFast):
+		return grpcstatus.FromHTTPStatus(failFast.StatusCode)
+	default: // 没有可用实例或连接失败
+		return grpcstatus.Unavailable
+	}
+}
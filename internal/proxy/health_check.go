@@ -0,0 +1,248 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+
+	"api-gateway/internal/balancer"
+	"api-gateway/internal/config"
+	"api-gateway/internal/metrics"
+	"go.uber.org/zap"
+)
+
+// 健康检查默认参数
+const (
+	defaultHealthCheckInterval = 10 * time.Second
+	defaultHealthCheckTimeout  = 2 * time.Second
+	defaultHealthyThreshold    = 2
+	defaultUnhealthyThreshold  = 3
+)
+
+// HealthCheckManager 管理所有 Upstream 的主动健康检查
+type HealthCheckManager struct {
+	reverseProxy *ReverseProxy
+	checkers     map[string]*runningChecker // Upstream 名称 -> 运行中的健康检查
+	metrics      *metrics.UpstreamMetrics
+	mu           sync.Mutex
+	logger       *zap.Logger
+}
+
+// runningChecker 运行中的健康检查及其配置
+type runningChecker struct {
+	cfg    config.HealthCheckConfig
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewHealthCheckManager 创建 HealthCheckManager
+func NewHealthCheckManager(reverseProxy *ReverseProxy, upstreamMetrics *metrics.UpstreamMetrics, logger *zap.Logger) *HealthCheckManager {
+	return &HealthCheckManager{
+		reverseProxy: reverseProxy,
+		checkers:     make(map[string]*runningChecker),
+		metrics:      upstreamMetrics,
+		logger:       logger,
+	}
+}
+
+// Sync 使运行中的健康检查与 configs (Upstream 名称 -> 配置) 一致，配置变化的检查会被重启
+func (m *HealthCheckManager) Sync(configs map[string]config.HealthCheckConfig) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for name, running := range m.checkers {
+		if cfg, ok := configs[name]; ok && reflect.DeepEqual(cfg, running.cfg) {
+			continue
+		}
+		m.stop(name, running)
+	}
+
+	for name, cfg := range configs {
+		if _, ok := m.checkers[name]; ok {
+			continue
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		running := &runningChecker{cfg: cfg, cancel: cancel, done: make(chan struct{})}
+		m.checkers[name] = running
+		checker := newHealthChecker(m.reverseProxy.GetUpstream(name), cfg, m.metrics, m.logger)
+		go func() {
+			defer close(running.done)
+			checker.run(ctx)
+		}()
+		m.logger.Info("启动主动健康检查", zap.String("upstream", name), zap.String("path", cfg.Path), zap.Duration("interval", checker.interval))
+	}
+}
+
+// Stop 停止所有健康检查
+func (m *HealthCheckManager) Stop() {
+	m.Sync(nil)
+}
+
+// stop 停止单个健康检查，并将其 Upstream 的实例恢复为健康，避免实例因检查停止而永久摘除
+func (m *HealthCheckManager) stop(name string, running *runningChecker) {
+	running.cancel()
+	<-running.done
+	delete(m.checkers, name)
+	for _, instance := range m.reverseProxy.GetUpstream(name).Instances() {
+		instance.SetHealthy(true)
+		m.metrics.DeleteInstance(name, instance.ID)
+	}
+	m.logger.Info("停止主动健康检查", zap.String("upstream", name))
+}
+
+// healthChecker 对单个 Upstream 的所有实例周期性地执行 HTTP 健康检查
+type healthChecker struct {
+	upstream           *Upstream
+	path               string
+	expectedStatuses   []int
+	interval           time.Duration
+	unhealthyThreshold int
+	healthyThreshold   int
+	client             *http.Client
+	counters           map[string]*healthCounter // 实例 ID -> 连续检查结果计数
+	metrics            *metrics.UpstreamMetrics
+	logger             *zap.Logger
+}
+
+// healthCounter 实例连续成功/失败次数
+type healthCounter struct {
+	successes int
+	failures  int
+}
+
+// newHealthChecker 创建 healthChecker，未配置的参数使用默认值
+func newHealthChecker(upstream *Upstream, cfg config.HealthCheckConfig, upstreamMetrics *metrics.UpstreamMetrics, logger *zap.Logger) *healthChecker {
+	hc := &healthChecker{
+		upstream:           upstream,
+		path:               cfg.Path,
+		expectedStatuses:   cfg.ExpectedStatuses,
+		interval:           cfg.Interval,
+		healthyThreshold:   cfg.HealthyThreshold,
+		unhealthyThreshold: cfg.UnhealthyThreshold,
+		counters:           make(map[string]*healthCounter),
+		metrics:            upstreamMetrics,
+		logger:             logger,
+	}
+	if hc.path == "" {
+		hc.path = "/"
+	}
+	if len(hc.expectedStatuses) == 0 {
+		hc.expectedStatuses = []int{http.StatusOK}
+	}
+	if hc.interval <= 0 {
+		hc.interval = defaultHealthCheckInterval
+	}
+	if hc.healthyThreshold <= 0 {
+		hc.healthyThreshold = defaultHealthyThreshold
+	}
+	if hc.unhealthyThreshold <= 0 {
+		hc.unhealthyThreshold = defaultUnhealthyThreshold
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultHealthCheckTimeout
+	}
+	hc.client = &http.Client{
+		Timeout: timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse // 不跟随重定向，直接按状态码判定
+		},
+	}
+	return hc
+}
+
+// run 周期性执行健康检查，直到 ctx 被取消
+func (hc *healthChecker) run(ctx context.Context) {
+	ticker := time.NewTicker(hc.interval)
+	defer ticker.Stop()
+
+	hc.checkAll(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			hc.checkAll(ctx)
+		}
+	}
+}
+
+// checkAll 并发检查当前所有实例
+func (hc *healthChecker) checkAll(ctx context.Context) {
+	instances := hc.upstream.Instances()
+	results := make([]bool, len(instances))
+
+	var wg sync.WaitGroup
+	for i, instance := range instances {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = hc.check(ctx, instance)
+		}()
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return
+	}
+
+	alive := make(map[string]struct{}, len(instances))
+	for i, instance := range instances {
+		alive[instance.ID] = struct{}{}
+		hc.record(instance, results[i])
+	}
+	// 清理已下线实例的计数与指标
+	for id := range hc.counters {
+		if _, ok := alive[id]; !ok {
+			delete(hc.counters, id)
+			hc.metrics.DeleteInstance(hc.upstream.Name(), id)
+		}
+	}
+}
+
+// check 对单个实例执行一次 HTTP 健康检查
+func (hc *healthChecker) check(ctx context.Context, instance *balancer.Instance) bool {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, instance.URL()+hc.path, nil)
+	if err != nil {
+		return false
+	}
+	req.Header.Set("User-Agent", "api-gateway-health-check")
+
+	resp, err := hc.client.Do(req)
+	if err != nil {
+		hc.logger.Debug("健康检查请求失败", zap.String("upstream", hc.upstream.Name()), zap.String("instance", instance.ID), zap.Error(err))
+		return false
+	}
+	resp.Body.Close()
+	return slices.Contains(hc.expectedStatuses, resp.StatusCode)
+}
+
+// record 根据检查结果更新连续计数，达到阈值时切换实例健康状态
+func (hc *healthChecker) record(instance *balancer.Instance, success bool) {
+	hc.metrics.ObserveHealthCheck(hc.upstream.Name(), success)
+
+	counter, ok := hc.counters[instance.ID]
+	if !ok {
+		counter = &healthCounter{}
+		hc.counters[instance.ID] = counter
+	}
+	if success {
+		counter.successes++
+		counter.failures = 0
+	} else {
+		counter.failures++
+		counter.successes = 0
+	}
+
+	switch {
+	case instance.Healthy() && counter.failures >= hc.unhealthyThreshold:
+		instance.SetHealthy(false)
+		hc.logger.Warn("实例健康检查失败，已从负载均衡中摘除", zap.String("upstream", hc.upstream.Name()), zap.String("instance", instance.ID), zap.Int("consecutive_failures", counter.failures))
+	case !instance.Healthy() && counter.successes >= hc.healthyThreshold:
+		instance.SetHealthy(true)
+		hc.logger.Info("实例健康检查恢复，重新加入负载均衡", zap.String("upstream", hc.upstream.Name()), zap.String("instance", instance.ID), zap.Int("consecutive_successes", counter.successes))
+	}
+	hc.metrics.SetInstanceHealthy(hc.upstream.Name(), instance.ID, instance.Healthy())
+}
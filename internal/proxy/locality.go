@@ -0,0 +1,131 @@
+package proxy
+
+import (
+	"net/http"
+	"slices"
+
+	"api-gateway/internal/balancer"
+	"api-gateway/internal/config"
+	"api-gateway/internal/metrics"
+)
+
+// 就近路由默认参数
+const (
+	defaultZoneMetaKey       = "zone"
+	defaultRegionMetaKey     = "region"
+	defaultMinHealthyPercent = 70
+)
+
+// 就近路由选择的实例所在位置
+const (
+	localityZone   = "zone"   // 与网关同可用区
+	localityRegion = "region" // 与网关同地域、不同可用区
+	localityRemote = "remote" // 其他地域或未标注位置
+)
+
+// localityBalancer 就近路由负载均衡器：在内层负载均衡器之前按实例位置筛选候选实例
+type localityBalancer struct {
+	upstream          *Upstream
+	inner             balancer.Balancer
+	zone              string
+	region            string
+	zoneKey           string
+	regionKey         string
+	minHealthyPercent int
+	metrics           *metrics.UpstreamMetrics
+}
+
+// LocalityAware 为 lb 包装就近路由，locality 为网关自身位置
+func (rp *ReverseProxy) LocalityAware(upstream *Upstream, lb balancer.Balancer, locality config.LocalityConfig, cfg config.LocalityRoutingConfig) balancer.Balancer {
+	b := &localityBalancer{
+		upstream:          upstream,
+		inner:             lb,
+		zone:              locality.Zone,
+		region:            locality.Region,
+		zoneKey:           locality.ZoneMetaKey,
+		regionKey:         locality.RegionMetaKey,
+		minHealthyPercent: cfg.MinHealthyPercent,
+		metrics:           rp.metrics,
+	}
+	if b.zoneKey == "" {
+		b.zoneKey = defaultZoneMetaKey
+	}
+	if b.regionKey == "" {
+		b.regionKey = defaultRegionMetaKey
+	}
+	if b.minHealthyPercent <= 0 {
+		b.minHealthyPercent = defaultMinHealthyPercent
+	}
+	return b
+}
+
+// Pick 依次尝试同可用区、同地域的实例，该层可用容量不低于阈值时只在该层内选择，否则溢出到更大范围
+func (b *localityBalancer) Pick(r *http.Request, instances []*balancer.Instance) *balancer.Instance {
+	return b.PickExcluding(r, instances, nil)
+}
+
+// PickExcluding 同 Pick，exclude 中的实例不计入可用容量，由内层负载均衡器在选择时跳过
+func (b *localityBalancer) PickExcluding(r *http.Request, instances, exclude []*balancer.Instance) *balancer.Instance {
+	all := b.upstream.Instances()
+	if b.zone != "" {
+		if local, ok := b.tier(all, instances, exclude, b.zoneKey, b.zone); ok {
+			return b.observe(balancer.PickExcluding(b.inner, r, local, exclude), localityZone)
+		}
+	}
+	if b.region != "" {
+		if local, ok := b.tier(all, instances, exclude, b.regionKey, b.region); ok {
+			return b.observe(balancer.PickExcluding(b.inner, r, local, exclude), localityRegion)
+		}
+	}
+	instance := balancer.PickExcluding(b.inner, r, instances, exclude)
+	if instance != nil {
+		return b.observe(instance, b.classify(instance))
+	}
+	return nil
+}
+
+// observe 记录实际选中实例所在的位置，未选中实例时不记录
+func (b *localityBalancer) observe(instance *balancer.Instance, locality string) *balancer.Instance {
+	if instance != nil {
+		b.metrics.ObserveLocality(b.upstream.Name(), locality)
+	}
+	return instance
+}
+
+// tier 返回 available 中位于 key=value 的实例；该位置可接收流量 (健康、未被弹出且不在 exclude 中) 的容量
+// 占该位置主实例 (不含备用实例) 总容量的比例低于阈值时返回 false
+func (b *localityBalancer) tier(all, available, exclude []*balancer.Instance, key, value string) ([]*balancer.Instance, bool) {
+	total := 0
+	for _, instance := range all {
+		if instance.Meta[key] == value && !instance.Backup {
+			total += instance.Weight
+		}
+	}
+
+	var local []*balancer.Instance
+	healthy := 0
+	for _, instance := range available {
+		if instance.Meta[key] == value {
+			local = append(local, instance)
+			if instance.Available() && !slices.Contains(exclude, instance) {
+				healthy += instance.Weight
+			}
+		}
+	}
+	if healthy == 0 || healthy*100 < total*b.minHealthyPercent {
+		return nil, false
+	}
+	return local, true
+}
+
+// classify 返回实例相对网关的位置
+func (b *localityBalancer) classify(instance *balancer.Instance) string {
+	switch {
+	case b.zone != "" && instance.Meta[b.zoneKey] == b.zone:
+		return localityZone
+	case b.region != "" && instance.Meta[b.regionKey] == b.region:
+		return localityRegion
+	default:
+		return localityRemote
+	}
+}
@@ -0,0 +1,179 @@
+package proxy
+
+import (
+	"reflect"
+	"sync"
+	"time"
+
+	"api-gateway/internal/balancer"
+	"api-gateway/internal/config"
+	"api-gateway/internal/metrics"
+	"go.uber.org/zap"
+)
+
+// 被动异常检测默认参数
+const (
+	defaultConsecutiveErrors  = 5
+	defaultOutlierMinRequests = 20
+	defaultOutlierInterval    = 10 * time.Second
+	defaultBaseEjectionTime   = 30 * time.Second
+	defaultMaxEjectionTime    = 5 * time.Minute
+	defaultMaxEjectionPercent = 50
+)
+
+// outlierDetector 根据真实流量统计单个 Upstream 内各实例的失败情况，并弹出异常实例
+type outlierDetector struct {
+	cfg      config.OutlierDetectionConfig // 原始配置，用于判断配置是否变化
+	settings config.OutlierDetectionConfig // 填充默认值后的配置
+	upstream string
+	stats    map[string]*outlierStats // 实例 ID -> 统计
+	mu       sync.Mutex
+	metrics  *metrics.UpstreamMetrics
+	logger   *zap.Logger
+}
+
+// outlierStats 单个实例的异常检测统计
+type outlierStats struct {
+	consecutiveErrors int
+	windowStart       time.Time
+	requests          int
+	errors            int
+	ejections         int       // 累计弹出次数，用于计算退避时长
+	lastEjectionEnd   time.Time // 最近一次弹出的结束时间
+}
+
+// newOutlierDetector 创建 outlierDetector，未配置的参数使用默认值
+func newOutlierDetector(upstream string, cfg config.OutlierDetectionConfig, upstreamMetrics *metrics.UpstreamMetrics, logger *zap.Logger) *outlierDetector {
+	settings := cfg
+	if settings.ConsecutiveErrors <= 0 {
+		settings.ConsecutiveErrors = defaultConsecutiveErrors
+	}
+	if settings.MinRequests <= 0 {
+		settings.MinRequests = defaultOutlierMinRequests
+	}
+	if settings.Interval <= 0 {
+		settings.Interval = defaultOutlierInterval
+	}
+	if settings.BaseEjectionTime <= 0 {
+		settings.BaseEjectionTime = defaultBaseEjectionTime
+	}
+	if settings.MaxEjectionTime <= 0 {
+		settings.MaxEjectionTime = defaultMaxEjectionTime
+	}
+	if settings.MaxEjectionPercent <= 0 {
+		settings.MaxEjectionPercent = defaultMaxEjectionPercent
+	}
+	return &outlierDetector{
+		cfg:      cfg,
+		settings: settings,
+		upstream: upstream,
+		stats:    make(map[string]*outlierStats),
+		metrics:  upstreamMetrics,
+		logger:   logger,
+	}
+}
+
+// record 记录一次请求结果，满足弹出条件时弹出实例
+func (od *outlierDetector) record(instance *balancer.Instance, success bool, instances []*balancer.Instance) {
+	od.mu.Lock()
+	defer od.mu.Unlock()
+
+	now := time.Now()
+	st, ok := od.stats[instance.ID]
+	if !ok {
+		st = &outlierStats{windowStart: now}
+		od.stats[instance.ID] = st
+	}
+	if now.Sub(st.windowStart) >= od.settings.Interval {
+		st.windowStart = now
+		st.requests, st.errors = 0, 0
+	}
+	// 实例在最近一次弹出结束后稳定运行足够久，退避倍数归零
+	if st.ejections > 0 && now.Sub(st.lastEjectionEnd) >= od.settings.MaxEjectionTime {
+		st.ejections = 0
+	}
+
+	st.requests++
+	if success {
+		st.consecutiveErrors = 0
+		return
+	}
+	st.errors++
+	st.consecutiveErrors++
+
+	if instance.Ejected() {
+		return
+	}
+	reason := ""
+	switch {
+	case st.consecutiveErrors >= od.settings.ConsecutiveErrors:
+		reason = "consecutive_errors"
+	case od.settings.ErrorRateThreshold > 0 && st.requests >= od.settings.MinRequests &&
+		float64(st.errors)/float64(st.requests) >= od.settings.ErrorRateThreshold:
+		reason = "error_rate"
+	default:
+		return
+	}
+
+	// 限制最大弹出比例，避免整个实例池被清空
+	ejected := 0
+	for _, other := range instances {
+		if other.Ejected() {
+			ejected++
+		}
+	}
+	if (ejected+1)*100 > od.settings.MaxEjectionPercent*len(instances) {
+		od.logger.Warn("已达到最大弹出比例，跳过实例弹出", zap.String("upstream", od.upstream), zap.String("instance", instance.ID), zap.Int("ejected", ejected), zap.Int("instance_count", len(instances)))
+		return
+	}
+
+	st.ejections++
+	duration := min(od.settings.BaseEjectionTime*time.Duration(st.ejections), od.settings.MaxEjectionTime)
+	st.lastEjectionEnd = now.Add(duration)
+	st.consecutiveErrors = 0
+	st.windowStart = now
+	st.requests, st.errors = 0, 0
+
+	instance.Eject(st.lastEjectionEnd)
+	od.metrics.ObserveEjection(od.upstream, reason)
+	od.metrics.SetInstanceEjected(od.upstream, instance.ID, true)
+	od.logger.Warn("实例异常，已弹出负载均衡", zap.String("upstream", od.upstream), zap.String("instance", instance.ID), zap.String("reason", reason), zap.Int("ejections", st.ejections), zap.Duration("duration", duration))
+
+	time.AfterFunc(duration, func() {
+		od.metrics.SetInstanceEjected(od.upstream, instance.ID, false)
+		od.logger.Info("实例弹出结束，重新加入负载均衡", zap.String("upstream", od.upstream), zap.String("instance", instance.ID))
+	})
+}
+
+// reinstateAll 异常检测被移除时结束所有实例的弹出状态，否则被弹出的实例要等到弹出时长结束才能恢复
+func (od *outlierDetector) reinstateAll(instances []*balancer.Instance) {
+	for _, instance := range instances {
+		if !instance.Ejected() {
+			continue
+		}
+		instance.Reinstate()
+		od.metrics.SetInstanceEjected(od.upstream, instance.ID, false)
+		od.logger.Info("异常检测已移除，实例重新加入负载均衡", zap.String("upstream", od.upstream), zap.String("instance", instance.ID))
+	}
+}
+
+// SyncOutlierDetection 使各 Upstream 的被动异常检测与 configs (Upstream 名称 -> 配置) 一致，配置未变化的保留原有统计；
+// 不再启用异常检测的 Upstream 立即恢复被弹出的实例
+func (rp *ReverseProxy) SyncOutlierDetection(configs map[string]config.OutlierDetectionConfig) {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	for name, upstream := range rp.upstreams {
+		cfg, ok := configs[name]
+		if !ok {
+			if current := upstream.outlier.Swap(nil); current != nil {
+				current.reinstateAll(upstream.Instances())
+			}
+			continue
+		}
+		if current := upstream.outlier.Load(); current != nil && reflect.DeepEqual(current.cfg, cfg) {
+			continue
+		}
+		upstream.outlier.Store(newOutlierDetector(name, cfg, rp.metrics, rp.logger))
+	}
+}
@@ -1,57 +1,90 @@
 package proxy
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httputil"
-	"net/url"
 	"sync"
 
+	"api-gateway/internal/metrics"
+	"api-gateway/pkg/grpcstatus"
 	"go.uber.org/zap"
 )
 
-// ReverseProxy 封装反向代理
+// ReverseProxy 封装反向代理，并管理所有 Upstream
 type ReverseProxy struct {
-	proxies map[string]*httputil.ReverseProxy
-	mu      sync.Mutex
-	logger  *zap.Logger // 传入 logger
+	upstreams     map[string]*Upstream
+	transport     http.RoundTripper
+	grpcTransport http.RoundTripper // 转发 gRPC 请求，使用 HTTP/2
+	metrics       *metrics.UpstreamMetrics
+	mu            sync.Mutex
+	logger        *zap.Logger // 传入 logger
 }
 
 // NewReverseProxy 创建 ReverseProxy
-func NewReverseProxy(logger *zap.Logger) *ReverseProxy {
+func NewReverseProxy(upstreamMetrics *metrics.UpstreamMetrics, logger *zap.Logger) *ReverseProxy {
 	return &ReverseProxy{
-		proxies: make(map[string]*httputil.ReverseProxy),
-		logger:  logger, // 存储 logger
+		upstreams:     make(map[string]*Upstream),
+		transport:     http.DefaultTransport,
+		grpcTransport: newGRPCTransport(),
+		metrics:       upstreamMetrics,
+		logger:        logger, // 存储 logger
 	}
 }
 
-// GetProxy 获取或创建指定 TargetURL 的反向代理
-func (rp *ReverseProxy) GetProxy(targetURLStr string) (*httputil.ReverseProxy, error) {
+// GetUpstream 获取或创建指定名称的 Upstream
+func (rp *ReverseProxy) GetUpstream(name string) *Upstream {
 	rp.mu.Lock()
 	defer rp.mu.Unlock()
 
-	if p, ok := rp.proxies[targetURLStr]; ok {
-		return p, nil
+	if u, ok := rp.upstreams[name]; ok {
+		return u
 	}
+	u := NewUpstream(name)
+	rp.upstreams[name] = u
+	return u
+}
 
-	targetURL, err := url.Parse(targetURLStr)
-	if err != nil {
-		return nil, err
+// RetainUpstreams 移除 names 之外的 Upstream，路由表更新后用于清理不再被任何路由使用的 Upstream
+func (rp *ReverseProxy) RetainUpstreams(names map[string]bool) {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	for name := range rp.upstreams {
+		if !names[name] {
+			delete(rp.upstreams, name)
+			rp.logger.Info("移除不再使用的 Upstream", zap.String("upstream", name))
+		}
 	}
+}
 
-	proxy := &httputil.ReverseProxy{ // 正确用法：直接使用结构体字面量创建 *httputil.ReverseProxy
-		Director: func(req *http.Request) { // Director 函数用于修改转发请求
-			req.URL.Scheme = targetURL.Scheme
-			req.URL.Host = targetURL.Host
-			req.URL.Path = targetURL.Path //  保留目标路径
-			req.Host = targetURL.Host     //  需要显式设置 Host 头
+// GetProxy 创建转发到指定 Upstream 的反向代理，transport 负责为每个请求选择实例并发送
+func (rp *ReverseProxy) GetProxy(upstream *Upstream, transport http.RoundTripper) *httputil.ReverseProxy {
+	return &httputil.ReverseProxy{
+		Director: func(req *http.Request) { // Director 函数用于修改转发请求，保留客户端路径 (已按路由配置重写)
+			req.URL.Scheme = "http"
+			req.URL.Host = upstream.Name() // 实际的实例地址在 Transport 中按请求选择
 		},
+		Transport: transport,
 		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) { // ErrorHandler 自定义错误处理
-			rp.logger.Error("反向代理错误", zap.String("path", r.URL.Path), zap.Error(err))
-			w.WriteHeader(http.StatusBadGateway) // 返回 502 Bad Gateway 错误
-			fmt.Fprintln(w, "反向代理错误")
+			rp.logger.Error("反向代理错误", zap.String("path", r.URL.Path), zap.String("upstream", upstream.Name()), zap.Error(err))
+			if IsGRPCRequest(r) { // gRPC 客户端只识别 grpc-status
+				grpcstatus.WriteError(w, grpcErrorCode(err), err.Error())
+				return
+			}
+			var failFast *FailFastError
+			switch {
+			case errors.As(err, &failFast):
+				w.WriteHeader(failFast.StatusCode) // 网关主动拒绝，按指定状态码响应
+				fmt.Fprintln(w, failFast.Body)
+			case errors.Is(err, ErrNoAvailableInstance):
+				w.WriteHeader(http.StatusServiceUnavailable) // 没有可用实例返回 503
+				fmt.Fprintln(w, "后端服务不可用")
+			default:
+				w.WriteHeader(http.StatusBadGateway) // 返回 502 Bad Gateway 错误
+				fmt.Fprintln(w, "反向代理错误")
+			}
 		},
 	}
-	rp.proxies[targetURLStr] = proxy
-	return proxy, nil
 }
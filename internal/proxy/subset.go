@@ -0,0 +1,68 @@
+package proxy
+
+import (
+	"maps"
+	"slices"
+	"strings"
+
+	"api-gateway/internal/balancer"
+	"api-gateway/internal/service/consul"
+)
+
+// ServiceSubset 服务发现中的一个服务，或按标签与元数据从中筛选出的一组实例
+type ServiceSubset struct {
+	ServiceName string
+	Tag         string            // 只保留带有该标签的实例 (可选)
+	Meta        map[string]string // 只保留元数据全部匹配的实例 (可选)
+}
+
+// UpstreamName 返回子集对应的 Upstream 名称，未配置筛选条件时即服务名
+func (s ServiceSubset) UpstreamName() string {
+	if s.Tag == "" && len(s.Meta) == 0 {
+		return s.ServiceName
+	}
+	var filters []string
+	if s.Tag != "" {
+		filters = append(filters, "tag="+s.Tag)
+	}
+	for _, key := range slices.Sorted(maps.Keys(s.Meta)) {
+		filters = append(filters, key+"="+s.Meta[key])
+	}
+	return s.ServiceName + "[" + strings.Join(filters, ",") + "]"
+}
+
+// Select 返回属于该子集的实例
+func (s ServiceSubset) Select(serviceInstances []*consul.ServiceInstance) []*consul.ServiceInstance {
+	if s.Tag == "" && len(s.Meta) == 0 {
+		return serviceInstances
+	}
+	selected := make([]*consul.ServiceInstance, 0, len(serviceInstances))
+	for _, si := range serviceInstances {
+		if s.Tag != "" && !slices.Contains(si.Tags, s.Tag) {
+			continue
+		}
+		matched := true
+		for key, value := range s.Meta {
+			if si.Meta[key] != value {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			selected = append(selected, si)
+		}
+	}
+	return selected
+}
+
+// Instances 将服务发现返回的实例按子集筛选并转换为负载均衡实例
+func (s ServiceSubset) Instances(serviceInstances []*consul.ServiceInstance) []*balancer.Instance {
+	return FromServiceInstances(s.Select(serviceInstances))
+}
+
+// SetServiceInstances 将服务发现返回的实例按子集筛选后同步到子集对应的 Upstream
+func (rp *ReverseProxy) SetServiceInstances(subset ServiceSubset, serviceInstances []*consul.ServiceInstance) *Upstream {
+	upstream := rp.GetUpstream(subset.UpstreamName())
+	upstream.SetInstances(subset.Instances(serviceInstances))
+	return upstream
+}
@@ -0,0 +1,98 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"api-gateway/internal/balancer"
+)
+
+// ErrNoAvailableInstance Upstream 中没有可用实例
+var ErrNoAvailableInstance = errors.New("没有可用的后端实例")
+
+// ErrRouteTimeout 路由请求超时的原因 (context.Cause)，用于区分路由超时与下游客户端取消
+var ErrRouteTimeout = errors.New("路由请求超时")
+
+// ClientCanceled 判断请求是否因下游客户端取消 (或客户端自身的超时) 而结束；
+// 路由超时说明后端没有及时响应，不属于客户端取消
+func ClientCanceled(ctx context.Context) bool {
+	return ctx.Err() != nil && !errors.Is(context.Cause(ctx), ErrRouteTimeout)
+}
+
+// FailFastError 网关主动拒绝请求 (例如熔断) 时返回的错误，ErrorHandler 按其状态码和内容响应客户端
+type FailFastError struct {
+	StatusCode int
+	Body       string
+	Err        error
+}
+
+func (e *FailFastError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *FailFastError) Unwrap() error {
+	return e.Err
+}
+
+// RoundTrip 将请求发送到 upstream 中的指定实例，维护实例的未完成请求计数并上报被动异常检测结果
+func (rp *ReverseProxy) RoundTrip(req *http.Request, upstream *Upstream, instance *balancer.Instance) (*http.Response, error) {
+	outreq := *req
+	outURL := *req.URL
+	outURL.Scheme = instance.Scheme
+	outURL.Host = instance.Addr()
+	if instance.Path != "" {
+		outURL.Path = instance.Path + "/" + strings.TrimPrefix(req.URL.Path, "/")
+		if req.URL.RawPath != "" {
+			outURL.RawPath = (&url.URL{Path: instance.Path}).EscapedPath() + "/" + strings.TrimPrefix(req.URL.RawPath, "/")
+		}
+	}
+	outreq.URL = &outURL
+	outreq.Host = instance.Addr()
+
+	transport := rp.transport
+	if IsGRPCRequest(req) {
+		transport = rp.grpcTransport
+	}
+	instance.Acquire()
+	resp, err := transport.RoundTrip(&outreq)
+	if err != nil {
+		instance.Release()
+		if !ClientCanceled(req.Context()) { // 客户端取消不计为实例故障，路由超时计为故障
+			upstream.reportResult(instance, false)
+		}
+		return nil, err
+	}
+	upstream.reportResult(instance, resp.StatusCode < http.StatusInternalServerError)
+	// 响应体读取完毕并关闭后才视为请求结束
+	body := &releaseOnClose{ReadCloser: resp.Body, release: instance.Release}
+	resp.Body = body
+	if conn, ok := body.ReadCloser.(io.ReadWriteCloser); ok && resp.StatusCode == http.StatusSwitchingProtocols {
+		// 协议升级后响应体即后端连接，反向代理需要向其写入，连接关闭时才视为请求结束
+		resp.Body = &upgradedBody{releaseOnClose: body, Writer: conn}
+	}
+	return resp, nil
+}
+
+// releaseOnClose 在响应体关闭时释放实例的未完成请求计数
+type releaseOnClose struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releaseOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+// upgradedBody 协议升级 (101) 响应的响应体，保留后端连接的写入能力
+type upgradedBody struct {
+	*releaseOnClose
+	io.Writer
+}
@@ -0,0 +1,160 @@
+package proxy
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"api-gateway/internal/balancer"
+	"api-gateway/internal/config"
+	"api-gateway/internal/service/consul"
+)
+
+// Upstream 一组后端实例 (对应一个服务发现服务名或一个静态目标)
+type Upstream struct {
+	name      string
+	instances atomic.Pointer[[]*balancer.Instance]
+	outlier   atomic.Pointer[outlierDetector] // 被动异常检测，未启用时为 nil
+}
+
+// NewUpstream 创建 Upstream
+func NewUpstream(name string) *Upstream {
+	u := &Upstream{name: name}
+	u.instances.Store(&[]*balancer.Instance{})
+	return u
+}
+
+// Name 返回 Upstream 名称
+func (u *Upstream) Name() string {
+	return u.name
+}
+
+// Instances 返回当前实例列表快照，调用方不可修改
+func (u *Upstream) Instances() []*balancer.Instance {
+	return *u.instances.Load()
+}
+
+// Available 返回当前可接收流量的实例 (排除健康检查失败和被异常检测弹出的实例)；
+// 主实例全部不可用时返回可用的备用实例
+func (u *Upstream) Available() []*balancer.Instance {
+	instances := u.Instances()
+	allPrimary := true
+	for _, instance := range instances {
+		if instance.Backup || !instance.Available() {
+			allPrimary = false
+			break
+		}
+	}
+	if allPrimary {
+		return instances // 常见情况下不分配新切片
+	}
+
+	var primary, backup []*balancer.Instance
+	for _, instance := range instances {
+		switch {
+		case !instance.Available():
+		case instance.Backup:
+			backup = append(backup, instance)
+		default:
+			primary = append(primary, instance)
+		}
+	}
+	if len(primary) > 0 {
+		return primary
+	}
+	return backup
+}
+
+// Pick 通过负载均衡器从可用实例中选择一个，exclude 中的实例不参与选择 (用于重试或跳过熔断的实例)
+func (u *Upstream) Pick(req *http.Request, lb balancer.Balancer, exclude ...*balancer.Instance) (*balancer.Instance, error) {
+	instance := balancer.PickExcluding(lb, req, u.Available(), exclude)
+	if instance == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoAvailableInstance, u.name)
+	}
+	return instance, nil
+}
+
+// reportResult 上报一次真实请求的结果，供被动异常检测使用
+func (u *Upstream) reportResult(instance *balancer.Instance, success bool) {
+	if od := u.outlier.Load(); od != nil {
+		od.record(instance, success, u.Instances())
+	}
+}
+
+// SetInstances 替换实例列表，ID、地址与元数据均未变化的实例沿用原对象，以保留其运行时状态 (如未完成请求数)
+func (u *Upstream) SetInstances(instances []*balancer.Instance) {
+	existing := make(map[string]*balancer.Instance)
+	for _, instance := range u.Instances() {
+		existing[instance.ID] = instance
+	}
+
+	merged := make([]*balancer.Instance, 0, len(instances))
+	for _, instance := range instances {
+		if old, ok := existing[instance.ID]; ok && sameInstance(old, instance) {
+			merged = append(merged, old)
+			continue
+		}
+		merged = append(merged, instance)
+	}
+	u.instances.Store(&merged)
+}
+
+// sameInstance 判断两个实例描述是否一致
+func sameInstance(a, b *balancer.Instance) bool {
+	return a.Scheme == b.Scheme && a.Host == b.Host && a.Port == b.Port && a.Weight == b.Weight && a.Backup == b.Backup && maps.Equal(a.Meta, b.Meta)
+}
+
+// FromServiceInstances 将服务发现返回的实例转换为负载均衡实例
+func FromServiceInstances(serviceInstances []*consul.ServiceInstance) []*balancer.Instance {
+	instances := make([]*balancer.Instance, 0, len(serviceInstances))
+	for _, si := range serviceInstances {
+		instances = append(instances, balancer.NewInstance(si.ID, "http", si.Host, si.Port, si.Meta))
+	}
+	return instances
+}
+
+// StaticInstances 将静态后端池的目标解析为负载均衡实例
+func StaticInstances(targets []config.TargetConfig) ([]*balancer.Instance, error) {
+	instances := make([]*balancer.Instance, 0, len(targets))
+	for _, target := range targets {
+		instance, err := StaticInstance(target.URL, target.Meta)
+		if err != nil {
+			return nil, err
+		}
+		if target.Weight > 0 {
+			instance.Weight = target.Weight
+		}
+		instance.Backup = instance.Backup || target.Backup
+		instances = append(instances, instance)
+	}
+	return instances, nil
+}
+
+// StaticInstance 将静态 TargetURL 解析为负载均衡实例，URL 中的路径作为转发路径的前缀
+func StaticInstance(targetURLStr string, meta map[string]string) (*balancer.Instance, error) {
+	targetURL, err := url.Parse(targetURLStr)
+	if err != nil {
+		return nil, err
+	}
+	if targetURL.Hostname() == "" {
+		return nil, fmt.Errorf("目标 URL 缺少主机名: %s", targetURLStr)
+	}
+
+	port := 80
+	if targetURL.Scheme == "https" {
+		port = 443
+	}
+	if p := targetURL.Port(); p != "" {
+		port, err = strconv.Atoi(p)
+		if err != nil {
+			return nil, fmt.Errorf("目标 URL 端口无效: %s", targetURLStr)
+		}
+	}
+	instance := balancer.NewInstance(targetURLStr, targetURL.Scheme, targetURL.Hostname(), port, meta)
+	instance.Path = strings.TrimSuffix(targetURL.Path, "/") // 目标 URL 的路径作为转发路径前缀
+	return instance, nil
+}
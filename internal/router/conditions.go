@@ -0,0 +1,57 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+
+	"api-gateway/internal/config"
+)
+
+// Conditions 请求头与 Cookie 条件，用于在路由内选择后端
+type Conditions struct {
+	headers []valueMatcher
+	cookies []valueMatcher
+	any     bool // true 时任一条件满足即匹配，否则需全部满足
+}
+
+// NewConditions 编译请求头与 Cookie 条件
+func NewConditions(headers, cookies []config.ValueMatchConfig, any bool) (*Conditions, error) {
+	c := &Conditions{any: any}
+	var err error
+	if c.headers, err = newValueMatchers(headers); err != nil {
+		return nil, fmt.Errorf("请求头匹配条件无效: %w", err)
+	}
+	if c.cookies, err = newValueMatchers(cookies); err != nil {
+		return nil, fmt.Errorf("Cookie 匹配条件无效: %w", err)
+	}
+	if len(c.headers)+len(c.cookies) == 0 {
+		return nil, fmt.Errorf("至少需要一个请求头或 Cookie 条件")
+	}
+	return c, nil
+}
+
+// Match 判断请求是否满足条件
+func (c *Conditions) Match(r *http.Request) bool {
+	for _, vm := range c.headers {
+		if vm.match(r.Header.Values(vm.name)) == c.any {
+			return c.any // any 时遇到满足的条件即匹配，否则遇到不满足的条件即不匹配
+		}
+	}
+	for _, vm := range c.cookies {
+		if vm.match(cookieValues(r, vm.name)) == c.any {
+			return c.any
+		}
+	}
+	return !c.any
+}
+
+// cookieValues 返回请求中名为 name 的所有 Cookie 值
+func cookieValues(r *http.Request, name string) []string {
+	var values []string
+	for _, cookie := range r.Cookies() {
+		if cookie.Name == name {
+			values = append(values, cookie.Value)
+		}
+	}
+	return values
+}
@@ -0,0 +1,242 @@
+package router
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"api-gateway/internal/config"
+)
+
+// 路径匹配方式
+const (
+	MatchExact  = "exact"
+	MatchPrefix = "prefix"
+	MatchRegex  = "regex"
+)
+
+// grpcContentTypeRegex gRPC 请求的 Content-Type (application/grpc、application/grpc+proto 等)
+const grpcContentTypeRegex = `^application/grpc([+;].*)?$`
+
+// Matcher 路由匹配条件 (路径、方法、Host、请求头、查询参数)，所有条件同时满足才匹配
+type Matcher struct {
+	matchType string
+	path      string
+	pathRegex *regexp.Regexp // matchType 为 regex 时使用
+	methods   []string
+	hosts     []string
+	headers   []valueMatcher
+	queries   []valueMatcher
+}
+
+// valueMatcher 请求头或查询参数的匹配条件
+type valueMatcher struct {
+	name  string
+	value string
+	regex *regexp.Regexp
+}
+
+// NewMatcher 根据路由配置创建 Matcher
+func NewMatcher(route config.RouteConfig) (*Matcher, error) {
+	path, matchType := route.MatchPath()
+	m := &Matcher{matchType: strings.ToLower(matchType), path: path}
+	switch m.matchType {
+	case "":
+		m.matchType = MatchExact
+	case MatchExact:
+	case MatchPrefix:
+		if !strings.HasPrefix(m.path, "/") {
+			return nil, fmt.Errorf("前缀路径必须以 / 开头: %s", m.path)
+		}
+	case MatchRegex:
+		re, err := regexp.Compile("^(?:" + m.path + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("路径正则表达式无效: %w", err)
+		}
+		m.pathRegex = re
+	default:
+		return nil, fmt.Errorf("未知的路径匹配方式: %s", matchType)
+	}
+
+	for _, method := range route.Methods {
+		m.methods = append(m.methods, strings.ToUpper(method))
+	}
+	for _, host := range route.Hosts {
+		m.hosts = append(m.hosts, strings.ToLower(host))
+	}
+
+	headers := route.Headers
+	if route.GRPC.Enabled && !route.GRPC.Web { // 只匹配 gRPC 请求；gRPC-Web 与预检请求由 GRPCHandler 区分
+		headers = append(slices.Clip(headers), config.ValueMatchConfig{Name: "Content-Type", Regex: grpcContentTypeRegex})
+	}
+	var err error
+	if m.headers, err = newValueMatchers(headers); err != nil {
+		return nil, fmt.Errorf("请求头匹配条件无效: %w", err)
+	}
+	if m.queries, err = newValueMatchers(route.QueryParams); err != nil {
+		return nil, fmt.Errorf("查询参数匹配条件无效: %w", err)
+	}
+	return m, nil
+}
+
+// newValueMatchers 编译请求头或查询参数的匹配条件
+func newValueMatchers(cfgs []config.ValueMatchConfig) ([]valueMatcher, error) {
+	matchers := make([]valueMatcher, 0, len(cfgs))
+	for _, cfg := range cfgs {
+		if cfg.Name == "" {
+			return nil, fmt.Errorf("缺少 name")
+		}
+		vm := valueMatcher{name: cfg.Name, value: cfg.Value}
+		if cfg.Regex != "" {
+			re, err := regexp.Compile(cfg.Regex)
+			if err != nil {
+				return nil, fmt.Errorf("%s 的正则表达式无效: %w", cfg.Name, err)
+			}
+			vm.regex = re
+		}
+		matchers = append(matchers, vm)
+	}
+	return matchers, nil
+}
+
+// Methods 返回允许的方法，为空表示不限制
+func (m *Matcher) Methods() []string {
+	return m.methods
+}
+
+// Match 判断请求是否满足路径、Host、请求头与查询参数条件 (方法由 mux 匹配)
+func (m *Matcher) Match(r *http.Request) bool {
+	if !m.matchPath(r.URL.Path) {
+		return false
+	}
+	if len(m.hosts) > 0 && !slices.ContainsFunc(m.hosts, func(pattern string) bool { return matchHost(pattern, requestHost(r)) }) {
+		return false
+	}
+	for _, vm := range m.headers {
+		if !vm.match(r.Header.Values(vm.name)) {
+			return false
+		}
+	}
+	if len(m.queries) > 0 {
+		query := r.URL.Query()
+		for _, vm := range m.queries {
+			if !vm.match(query[vm.name]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// matchPath 判断路径是否匹配
+func (m *Matcher) matchPath(path string) bool {
+	switch m.matchType {
+	case MatchPrefix:
+		if path == m.path || strings.HasSuffix(m.path, "/") && strings.HasPrefix(path, m.path) {
+			return true
+		}
+		return strings.HasPrefix(path, m.path+"/") // 按路径段匹配，/api/users 不匹配 /api/usersX
+	case MatchRegex:
+		return m.pathRegex.MatchString(path)
+	default:
+		return path == m.path
+	}
+}
+
+// CaptureNames 返回 regex 路径可引用的捕获组：编号 ("1"、"2" ...) 与命名捕获组，非 regex 路由返回 nil
+func (m *Matcher) CaptureNames() []string {
+	if m.pathRegex == nil {
+		return nil
+	}
+	var names []string
+	for i, name := range m.pathRegex.SubexpNames() {
+		if i == 0 {
+			continue
+		}
+		names = append(names, strconv.Itoa(i))
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// captures 返回 regex 路径的捕获组：编号 ("1"、"2" ...) 与命名捕获组均可引用
+func (m *Matcher) captures(path string) map[string]string {
+	if m.pathRegex == nil {
+		return nil
+	}
+	groups := m.pathRegex.FindStringSubmatch(path)
+	if groups == nil {
+		return nil
+	}
+	captures := make(map[string]string, len(groups)*2)
+	for i, name := range m.pathRegex.SubexpNames() {
+		if i == 0 {
+			continue
+		}
+		captures[strconv.Itoa(i)] = groups[i]
+		if name != "" {
+			captures[name] = groups[i]
+		}
+	}
+	return captures
+}
+
+// capturesContextKey 请求上下文中路径捕获组的 key
+type capturesContextKey struct{}
+
+// PathCaptures 返回请求匹配到的 regex 路径捕获组，非 regex 路由返回 nil
+func PathCaptures(r *http.Request) map[string]string {
+	captures, _ := r.Context().Value(capturesContextKey{}).(map[string]string)
+	return captures
+}
+
+// rawCapturesContextKey 请求上下文中转义形式路径的捕获组的 key
+type rawCapturesContextKey struct{}
+
+// RawPathCaptures 返回按转义形式的路径 (URL.RawPath) 匹配到的 regex 捕获组，路径不含需要保留的转义或非 regex 路由返回 nil
+func RawPathCaptures(r *http.Request) map[string]string {
+	captures, _ := r.Context().Value(rawCapturesContextKey{}).(map[string]string)
+	return captures
+}
+
+// match 任一取值满足条件即匹配；未配置 value 与 regex 时只要求存在
+func (vm valueMatcher) match(values []string) bool {
+	if len(values) == 0 {
+		return false
+	}
+	if vm.value == "" && vm.regex == nil {
+		return true
+	}
+	for _, v := range values {
+		if vm.regex != nil && vm.regex.MatchString(v) {
+			return true
+		}
+		if vm.value != "" && v == vm.value {
+			return true
+		}
+	}
+	return false
+}
+
+// requestHost 返回请求的 Host (小写，不含端口)
+func requestHost(r *http.Request) string {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(host)
+}
+
+// matchHost 判断 host 是否匹配 pattern，"*.example.com" 匹配 example.com 的任意子域名
+func matchHost(pattern, host string) bool {
+	if suffix, ok := strings.CutPrefix(pattern, "*"); ok {
+		return strings.HasSuffix(host, suffix) && len(host) > len(suffix)
+	}
+	return pattern == host
+}
@@ -0,0 +1,251 @@
+package router
+
+import (
+	"cmp"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// 路由冲突类型
+const (
+	ConflictDuplicateName = "duplicate_name" // 路由名称重复
+	ConflictDuplicate     = "duplicate"      // 匹配条件完全相同
+	ConflictShadowed      = "shadowed"       // 被优先级更高的路由完全覆盖，永远不会被匹配
+	ConflictAmbiguous     = "ambiguous"      // 优先级与具体程度相同且可能同时匹配，由配置顺序决定
+)
+
+// Route 网关路由
+type Route struct {
+	ID       string
+	Priority int // 数值越大越先匹配
+	Matcher  *Matcher
+	Handler  http.HandlerFunc
+}
+
+// Conflict 路由冲突，Route 与先于它匹配的 Other 冲突
+type Conflict struct {
+	Kind        string
+	VirtualHost string // 冲突所在的虚拟主机，顶层路由为空
+	Route       string
+	Other       string
+}
+
+// Fatal 是否阻止新路由表生效，ambiguous 只作为警告
+func (c Conflict) Fatal() bool {
+	return c.Kind != ConflictAmbiguous
+}
+
+func (c Conflict) String() string {
+	if c.VirtualHost != "" {
+		return "虚拟主机 " + c.VirtualHost + ": " + c.describe()
+	}
+	return c.describe()
+}
+
+// describe 描述冲突内容
+func (c Conflict) describe() string {
+	switch c.Kind {
+	case ConflictDuplicateName:
+		return fmt.Sprintf("路由 %s 的名称重复", c.Route)
+	case ConflictDuplicate:
+		return fmt.Sprintf("路由 %s 与 %s 的匹配条件相同", c.Route, c.Other)
+	case ConflictShadowed:
+		return fmt.Sprintf("路由 %s 被 %s 完全覆盖，永远不会被匹配", c.Route, c.Other)
+	default:
+		return fmt.Sprintf("路由 %s 与 %s 可能匹配相同的请求，按配置顺序 %s 优先", c.Route, c.Other, c.Other)
+	}
+}
+
+// ConflictError 路由表存在阻止生效的冲突
+type ConflictError struct {
+	Conflicts []Conflict
+}
+
+func (e *ConflictError) Error() string {
+	msgs := make([]string, 0, len(e.Conflicts))
+	for _, c := range e.Conflicts {
+		msgs = append(msgs, c.String())
+	}
+	return "路由冲突: " + strings.Join(msgs, "; ")
+}
+
+// SortRoutes 按匹配顺序稳定排序路由：
+// priority 高的优先；相同 priority 时 exact > regex > prefix，前缀更长的优先 (最长匹配)，匹配条件更多的优先；其余按配置顺序
+func SortRoutes(routes []Route) {
+	sort.SliceStable(routes, func(i, j int) bool {
+		return compareRoutes(routes[i], routes[j]) < 0
+	})
+}
+
+// compareRoutes 比较两条路由的匹配顺序，返回 0 表示由配置顺序决定
+func compareRoutes(a, b Route) int {
+	if a.Priority != b.Priority {
+		return cmp.Compare(b.Priority, a.Priority)
+	}
+	ma, mb := a.Matcher, b.Matcher
+	if ra, rb := matchTypeRank(ma.matchType), matchTypeRank(mb.matchType); ra != rb {
+		return cmp.Compare(rb, ra)
+	}
+	if ma.matchType != MatchRegex && len(ma.path) != len(mb.path) {
+		return cmp.Compare(len(mb.path), len(ma.path))
+	}
+	return cmp.Compare(mb.conditionCount(), ma.conditionCount())
+}
+
+// matchTypeRank 路径匹配方式的具体程度
+func matchTypeRank(matchType string) int {
+	switch matchType {
+	case MatchExact:
+		return 3
+	case MatchRegex:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// conditionCount 路径之外的匹配条件数量
+func (m *Matcher) conditionCount() int {
+	n := len(m.headers) + len(m.queries)
+	if len(m.methods) > 0 {
+		n++
+	}
+	if len(m.hosts) > 0 {
+		n++
+	}
+	return n
+}
+
+// DetectConflicts 检测已按匹配顺序排序的路由之间的冲突
+func DetectConflicts(routes []Route) []Conflict {
+	var conflicts []Conflict
+	names := make(map[string]bool, len(routes))
+	for _, route := range routes {
+		if names[route.ID] {
+			conflicts = append(conflicts, Conflict{Kind: ConflictDuplicateName, Route: route.ID, Other: route.ID})
+		}
+		names[route.ID] = true
+	}
+
+	for j := range routes {
+		for i := 0; i < j; i++ {
+			earlier, later := routes[i], routes[j]
+			if covers(earlier.Matcher, later.Matcher) {
+				kind := ConflictShadowed
+				if covers(later.Matcher, earlier.Matcher) {
+					kind = ConflictDuplicate
+				}
+				conflicts = append(conflicts, Conflict{Kind: kind, Route: later.ID, Other: earlier.ID})
+				break
+			}
+			if compareRoutes(earlier, later) == 0 && mayOverlap(earlier.Matcher, later.Matcher) {
+				conflicts = append(conflicts, Conflict{Kind: ConflictAmbiguous, Route: later.ID, Other: earlier.ID})
+			}
+		}
+	}
+	return conflicts
+}
+
+// covers 判断 a 是否匹配 b 能匹配的所有请求
+func covers(a, b *Matcher) bool {
+	if !coversPath(a, b) {
+		return false
+	}
+	if len(a.methods) > 0 && (len(b.methods) == 0 || !containsAll(a.methods, b.methods)) {
+		return false
+	}
+	if len(a.hosts) > 0 {
+		if len(b.hosts) == 0 {
+			return false
+		}
+		for _, host := range b.hosts {
+			if !slices.ContainsFunc(a.hosts, func(pattern string) bool {
+				return pattern == host || !strings.HasPrefix(host, "*") && matchHost(pattern, host)
+			}) {
+				return false
+			}
+		}
+	}
+	return containsMatchers(b.headers, a.headers, true) && containsMatchers(b.queries, a.queries, false)
+}
+
+// coversPath 判断 a 的路径条件是否包含 b 的路径条件
+func coversPath(a, b *Matcher) bool {
+	switch a.matchType {
+	case MatchExact:
+		return b.matchType == MatchExact && a.path == b.path
+	case MatchPrefix:
+		return b.matchType != MatchRegex && a.matchPath(b.path)
+	default:
+		return b.matchType == MatchRegex && a.path == b.path || b.matchType == MatchExact && a.pathRegex.MatchString(b.path)
+	}
+}
+
+// mayOverlap 判断 a 与 b 是否可能匹配同一个请求 (无法确定时视为可能)
+func mayOverlap(a, b *Matcher) bool {
+	if len(a.methods) > 0 && len(b.methods) > 0 && !slices.ContainsFunc(a.methods, func(method string) bool { return slices.Contains(b.methods, method) }) {
+		return false
+	}
+	if len(a.hosts) > 0 && len(b.hosts) > 0 && !slices.ContainsFunc(a.hosts, func(ha string) bool {
+		return slices.ContainsFunc(b.hosts, func(hb string) bool {
+			return ha == hb || matchHost(ha, hb) || matchHost(hb, ha) || strings.HasPrefix(ha, "*") && strings.HasPrefix(hb, "*")
+		})
+	}) {
+		return false
+	}
+
+	switch {
+	case a.matchType == MatchExact:
+		return b.matchPath(a.path)
+	case b.matchType == MatchExact:
+		return a.matchPath(b.path)
+	case a.matchType == MatchPrefix && b.matchType == MatchPrefix:
+		return a.matchPath(b.path) || b.matchPath(a.path)
+	default: // 包含 regex 时比较字面量前缀
+		pa, pb := literalPrefix(a), literalPrefix(b)
+		return strings.HasPrefix(pa, pb) || strings.HasPrefix(pb, pa)
+	}
+}
+
+// literalPrefix 返回路径条件的字面量前缀
+func literalPrefix(m *Matcher) string {
+	if m.pathRegex == nil {
+		return m.path
+	}
+	prefix, _ := m.pathRegex.LiteralPrefix()
+	return prefix
+}
+
+// containsAll 判断 set 是否包含 values 的所有元素
+func containsAll(set, values []string) bool {
+	for _, v := range values {
+		if !slices.Contains(set, v) {
+			return false
+		}
+	}
+	return true
+}
+
+// containsMatchers 判断 set 是否包含 matchers 中的每个条件
+func containsMatchers(set, matchers []valueMatcher, caseInsensitive bool) bool {
+	for _, vm := range matchers {
+		if !slices.ContainsFunc(set, func(other valueMatcher) bool { return vm.equal(other, caseInsensitive) }) {
+			return false
+		}
+	}
+	return true
+}
+
+// equal 判断两个条件是否相同
+func (vm valueMatcher) equal(other valueMatcher, caseInsensitive bool) bool {
+	if caseInsensitive && !strings.EqualFold(vm.name, other.name) || !caseInsensitive && vm.name != other.name {
+		return false
+	}
+	if vm.value != other.value || (vm.regex == nil) != (other.regex == nil) {
+		return false
+	}
+	return vm.regex == nil || vm.regex.String() == other.regex.String()
+}
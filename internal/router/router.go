@@ -1,22 +1,60 @@
 package router
 
 import (
+	"context"
+	"fmt"
 	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/gorilla/mux"
 )
 
-// Router 封装 gorilla/mux.Router
+// Router 网关路由器：路由表在旁路完整构建后通过原子指针整体发布，
+// 请求开始时取得的路由表在请求结束前不会改变，重新加载期间不会看到构建了一半或空的路由表
 type Router struct {
-	*mux.Router
+	table       atomic.Pointer[routeTable]
+	mu          sync.Mutex // 串行化路由表的构建与发布
 	middlewares []func(http.Handler) http.Handler
+	fixed       []fixedRoute // 网关自身的路由 (如 /metrics)，在每个虚拟主机中先于网关路由匹配，重新加载时保留
+}
+
+// VirtualHost 虚拟主机，按请求的 Host 选择各自的路由
+type VirtualHost struct {
+	Name    string
+	Domains []string // 支持 "*.example.com" 形式的通配符
+	Routes  []Route
+}
+
+// routeTable 一个版本的路由表，发布后不再修改
+type routeTable struct {
+	version   uint64
+	exact     map[string]*mux.Router // 精确域名 -> 虚拟主机
+	wildcards []wildcardHost         // 按后缀长度降序排列
+	fallback  *mux.Router            // 未匹配任何虚拟主机时使用的顶层路由
+	routes    []Route                // 顶层路由，已按匹配顺序排序
+	vhosts    []VirtualHost          // 虚拟主机，路由已按匹配顺序排序
+}
+
+// wildcardHost 通配符域名对应的虚拟主机
+type wildcardHost struct {
+	pattern string
+	mux     *mux.Router
+}
+
+// fixedRoute 通过 HandleFunc 注册的路由
+type fixedRoute struct {
+	path    string
+	handler http.HandlerFunc
 }
 
 // NewRouter 创建一个新的 Router
 func NewRouter() *Router {
-	return &Router{
-		Router: mux.NewRouter(),
-	}
+	r := &Router{}
+	r.table.Store(&routeTable{fallback: mux.NewRouter()})
+	return r
 }
 
 // Use 添加中间件
@@ -24,16 +62,157 @@ func (r *Router) Use(middleware func(http.Handler) http.Handler) {
 	r.middlewares = append(r.middlewares, middleware)
 }
 
+// ServeHTTP 使用当前版本的路由表处理请求：先按 Host 选择虚拟主机 (精确域名优先，其次最长的通配符域名)，未匹配时使用顶层路由
+func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.table.Load().lookup(requestHost(req)).ServeHTTP(w, req)
+}
+
+// lookup 返回 host 对应的路由
+func (t *routeTable) lookup(host string) *mux.Router {
+	if m, ok := t.exact[host]; ok {
+		return m
+	}
+	for _, w := range t.wildcards {
+		if matchHost(w.pattern, host) {
+			return w.mux
+		}
+	}
+	return t.fallback
+}
+
+// Version 返回当前路由表的版本号，每次发布新路由表时递增
+func (r *Router) Version() uint64 {
+	return r.table.Load().version
+}
+
 // HandleFunc 注册路由处理函数，并应用中间件
 func (r *Router) HandleFunc(path string, handler http.HandlerFunc) {
 	// 倒序应用中间件，保证中间件执行顺序
 	for i := len(r.middlewares) - 1; i >= 0; i-- {
 		handler = r.middlewares[i](handler).(http.HandlerFunc)
 	}
-	r.Router.HandleFunc(path, handler) // 直接使用传参的 handler
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fixed = append(r.fixed, fixedRoute{path: path, handler: handler})
+	current := r.table.Load()
+	r.publish(current.routes, current.vhosts)
+}
+
+// SetRoutes 只使用顶层路由、不区分虚拟主机时的 SetVirtualHosts
+func (r *Router) SetRoutes(routes []Route) ([]Conflict, error) {
+	return r.SetVirtualHosts(routes, nil)
+}
+
+// SetVirtualHosts 按匹配顺序排序顶层路由与各虚拟主机的路由并检测冲突，没有阻止生效的冲突时发布新路由表；
+// 返回检测到的全部冲突，存在阻止生效的冲突或域名重复时返回错误并保留原路由表
+func (r *Router) SetVirtualHosts(routes []Route, vhosts []VirtualHost) ([]Conflict, error) {
+	domains := make(map[string]string)
+	for _, vhost := range vhosts {
+		if len(vhost.Domains) == 0 {
+			return nil, fmt.Errorf("虚拟主机 %s 未配置域名", vhost.Name)
+		}
+		for _, domain := range vhost.Domains {
+			domain = strings.ToLower(domain)
+			if other, ok := domains[domain]; ok {
+				return nil, fmt.Errorf("域名 %s 同时属于虚拟主机 %s 与 %s", domain, other, vhost.Name)
+			}
+			domains[domain] = vhost.Name
+		}
+	}
+
+	routes = sortedRoutes(routes)
+	conflicts := DetectConflicts(routes)
+	sorted := make([]VirtualHost, 0, len(vhosts))
+	for _, vhost := range vhosts {
+		vhost.Routes = sortedRoutes(vhost.Routes)
+		for _, c := range DetectConflicts(vhost.Routes) {
+			c.VirtualHost = vhost.Name
+			conflicts = append(conflicts, c)
+		}
+		sorted = append(sorted, vhost)
+	}
+
+	var fatal []Conflict
+	for _, c := range conflicts {
+		if c.Fatal() {
+			fatal = append(fatal, c)
+		}
+	}
+	if len(fatal) > 0 {
+		return conflicts, &ConflictError{Conflicts: fatal}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.publish(routes, sorted)
+	return conflicts, nil
+}
+
+// sortedRoutes 返回按匹配顺序排序的路由副本
+func sortedRoutes(routes []Route) []Route {
+	routes = append([]Route(nil), routes...)
+	SortRoutes(routes)
+	return routes
+}
+
+// publish 构建包含固定路由、顶层路由与虚拟主机的新路由表并原子替换当前路由表，调用方需持有 r.mu
+func (r *Router) publish(routes []Route, vhosts []VirtualHost) {
+	table := &routeTable{
+		version:  r.table.Load().version + 1,
+		exact:    make(map[string]*mux.Router),
+		fallback: r.newMux(routes),
+		routes:   routes,
+		vhosts:   vhosts,
+	}
+	for _, vhost := range vhosts {
+		m := r.newMux(vhost.Routes)
+		for _, domain := range vhost.Domains {
+			domain = strings.ToLower(domain)
+			if strings.HasPrefix(domain, "*") {
+				table.wildcards = append(table.wildcards, wildcardHost{pattern: domain, mux: m})
+			} else {
+				table.exact[domain] = m
+			}
+		}
+	}
+	sort.SliceStable(table.wildcards, func(i, j int) bool {
+		return len(table.wildcards[i].pattern) > len(table.wildcards[j].pattern)
+	})
+	r.table.Store(table)
+}
+
+// newMux 创建包含固定路由与 routes 的 mux.Router
+func (r *Router) newMux(routes []Route) *mux.Router {
+	m := mux.NewRouter()
+	for _, f := range r.fixed {
+		m.HandleFunc(f.path, f.handler)
+	}
+	for _, route := range routes {
+		r.handleRoute(m, route.Matcher, route.Handler)
+	}
+	return m
 }
 
-// ClearRoutes 清空所有已注册的路由规则
-func (r *Router) ClearRoutes() {
-	r.Router = mux.NewRouter() //  直接创建一个新的 Router 实例即可清空
+// handleRoute 注册带有匹配条件的路由处理函数，并应用中间件；regex 路径的捕获组可通过 PathCaptures 与 RawPathCaptures 获取
+func (r *Router) handleRoute(m *mux.Router, matcher *Matcher, handler http.HandlerFunc) {
+	if matcher.pathRegex != nil {
+		next := handler
+		handler = func(w http.ResponseWriter, req *http.Request) {
+			ctx := context.WithValue(req.Context(), capturesContextKey{}, matcher.captures(req.URL.Path))
+			if req.URL.RawPath != "" { // 路径含有 %2F 等需要保留的转义时，同时提供转义形式的捕获组
+				ctx = context.WithValue(ctx, rawCapturesContextKey{}, matcher.captures(req.URL.RawPath))
+			}
+			next(w, req.WithContext(ctx))
+		}
+	}
+	for i := len(r.middlewares) - 1; i >= 0; i-- {
+		handler = r.middlewares[i](handler).(http.HandlerFunc)
+	}
+	route := m.NewRoute().MatcherFunc(func(req *http.Request, _ *mux.RouteMatch) bool {
+		return matcher.Match(req)
+	}).HandlerFunc(handler)
+	if methods := matcher.Methods(); len(methods) > 0 {
+		route.Methods(methods...)
+	}
 }
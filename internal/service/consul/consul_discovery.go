@@ -1,9 +1,11 @@
 package consul
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"strconv"
+	"time"
 
 	"github.com/hashicorp/consul/api"
 	"go.uber.org/zap"
@@ -14,16 +16,25 @@ type ServiceInstance struct {
 	ID   string
 	Host string
 	Port int
+	Tags []string
 	Meta map[string]string //  元数据，可以扩展
 }
 
 // ServiceDiscovery 服务发现接口
 type ServiceDiscovery interface {
 	GetServiceInstances(serviceName string) ([]*ServiceInstance, error)
+	// WatchServiceInstances 持续监听服务的健康实例，实例集合每次变化时调用 onChange，直到 ctx 被取消
+	WatchServiceInstances(ctx context.Context, serviceName string, onChange func([]*ServiceInstance)) error
 	RegisterService(serviceName string, host string, port int, healthCheckURL string, meta map[string]string) error
 	DeregisterService(serviceID string) error
 }
 
+const (
+	watchWaitTime       = 5 * time.Minute  // 阻塞查询的最长等待时间
+	watchRetryBaseDelay = 1 * time.Second  // 阻塞查询失败后的初始重试间隔
+	watchRetryMaxDelay  = 30 * time.Second // 阻塞查询失败后的最大重试间隔
+)
+
 // ConsulServiceDiscovery Consul 服务发现实现
 type ConsulServiceDiscovery struct {
 	client *api.Client
@@ -53,12 +64,52 @@ func (sd *ConsulServiceDiscovery) GetServiceInstances(serviceName string) ([]*Se
 		return nil, fmt.Errorf("从 Consul 查询服务实例失败: %w", err)
 	}
 
+	return toServiceInstances(services), nil
+}
+
+// WatchServiceInstances 使用 Consul 基于 index 的阻塞查询监听服务实例变化
+func (sd *ConsulServiceDiscovery) WatchServiceInstances(ctx context.Context, serviceName string, onChange func([]*ServiceInstance)) error {
+	var lastIndex uint64
+	retryDelay := watchRetryBaseDelay
+	for {
+		opts := (&api.QueryOptions{WaitIndex: lastIndex, WaitTime: watchWaitTime}).WithContext(ctx)
+		services, meta, err := sd.client.Health().Service(serviceName, "", true, opts)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			sd.logger.Warn("Consul 阻塞查询失败，稍后重试", zap.String("service_name", serviceName), zap.Duration("retry_delay", retryDelay), zap.Error(err))
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(retryDelay):
+			}
+			retryDelay = min(retryDelay*2, watchRetryMaxDelay)
+			continue
+		}
+		retryDelay = watchRetryBaseDelay
+
+		if meta.LastIndex == lastIndex {
+			continue // 等待超时，实例没有变化
+		}
+		if meta.LastIndex < lastIndex {
+			lastIndex = 0 // index 回退 (例如 Consul 重启)，按 Consul 的建议重置
+		} else {
+			lastIndex = meta.LastIndex
+		}
+		onChange(toServiceInstances(services))
+	}
+}
+
+// toServiceInstances 将 Consul 健康查询结果转换为 ServiceInstance 列表
+func toServiceInstances(services []*api.ServiceEntry) []*ServiceInstance {
 	instances := make([]*ServiceInstance, 0, len(services))
 	for _, service := range services {
 		instance := &ServiceInstance{
 			ID:   service.Service.ID,
 			Host: service.Service.Address, //  使用服务注册时提供的地址
 			Port: service.Service.Port,
+			Tags: service.Service.Tags,
 			Meta: service.Service.Meta,
 		}
 		//  如果 Address 为空，尝试从 Node 地址获取 (例如 Consul Agent 和 Service 运行在同一主机)
@@ -67,7 +118,7 @@ func (sd *ConsulServiceDiscovery) GetServiceInstances(serviceName string) ([]*Se
 		}
 		instances = append(instances, instance)
 	}
-	return instances, nil
+	return instances
 }
 
 // RegisterService 将服务注册到 Consul
@@ -0,0 +1,196 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"api-gateway/internal/config"
+	"api-gateway/internal/metrics"
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+)
+
+// reloadDelay 证书文件变化后等待的时间，证书与私钥通常先后写入，合并为一次重新加载
+const reloadDelay = 500 * time.Millisecond
+
+// CertificateStore 按 SNI 选择证书；监听证书文件所在目录，文件变化时重新加载全部证书，加载失败时保留原证书
+type CertificateStore struct {
+	configs []config.CertificateConfig
+	table   atomic.Pointer[certificateTable]
+	metrics *metrics.TLSMetrics
+	logger  *zap.Logger
+
+	mu      sync.Mutex
+	watcher *fsnotify.Watcher
+	timer   *time.Timer
+}
+
+// certificateTable 一次加载得到的证书，按域名索引
+type certificateTable struct {
+	defaultCert *tls.Certificate
+	exact       map[string]*tls.Certificate // 精确域名
+	wildcard    map[string]*tls.Certificate // "*.example.com" 以 "example.com" 为键
+}
+
+// NewCertificateStore 加载证书并创建 CertificateStore
+func NewCertificateStore(configs []config.CertificateConfig, tlsMetrics *metrics.TLSMetrics, logger *zap.Logger) (*CertificateStore, error) {
+	if len(configs) == 0 {
+		return nil, fmt.Errorf("未配置证书")
+	}
+	s := &CertificateStore{configs: configs, metrics: tlsMetrics, logger: logger}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// GetCertificate 按客户端的 SNI 选择证书：精确域名优先，其次通配符，都没有匹配或未发送 SNI 时使用默认证书
+func (s *CertificateStore) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	table := s.table.Load()
+	name := strings.TrimSuffix(strings.ToLower(hello.ServerName), ".")
+	if cert, ok := table.exact[name]; ok {
+		return cert, nil
+	}
+	if _, parent, ok := strings.Cut(name, "."); ok {
+		if cert, ok := table.wildcard[parent]; ok {
+			return cert, nil
+		}
+	}
+	return table.defaultCert, nil
+}
+
+// load 加载全部证书，全部成功后才替换当前生效的证书
+func (s *CertificateStore) load() error {
+	table := &certificateTable{
+		exact:    make(map[string]*tls.Certificate),
+		wildcard: make(map[string]*tls.Certificate),
+	}
+	expiry := make(map[string]time.Time, len(s.configs))
+	for i, certConfig := range s.configs {
+		cert, err := tls.LoadX509KeyPair(certConfig.CertFile, certConfig.KeyFile)
+		if err != nil {
+			return fmt.Errorf("加载第 %d 张证书 %s 失败: %w", i+1, certConfig.CertFile, err)
+		}
+		if i == 0 {
+			table.defaultCert = &cert
+		}
+		expiry[certConfig.CertFile] = cert.Leaf.NotAfter
+
+		names := certConfig.ServerNames
+		if len(names) == 0 {
+			names = cert.Leaf.DNSNames
+			if len(names) == 0 && cert.Leaf.Subject.CommonName != "" {
+				names = []string{cert.Leaf.Subject.CommonName}
+			}
+		}
+		for _, name := range names { // 多张证书包含同一域名时，配置在前的证书优先
+			name = strings.ToLower(name)
+			if parent, ok := strings.CutPrefix(name, "*."); ok {
+				if _, exists := table.wildcard[parent]; !exists {
+					table.wildcard[parent] = &cert
+				}
+			} else if _, exists := table.exact[name]; !exists {
+				table.exact[name] = &cert
+			}
+		}
+		if time.Until(cert.Leaf.NotAfter) < 0 {
+			s.logger.Warn("证书已过期", zap.String("cert_file", certConfig.CertFile), zap.Time("not_after", cert.Leaf.NotAfter))
+		}
+	}
+	s.table.Store(table)
+	s.metrics.SetCertificates(expiry)
+	return nil
+}
+
+// Watch 监听证书与私钥文件所在的目录 (兼容通过符号链接原子替换证书的部署方式)，文件变化时重新加载证书
+func (s *CertificateStore) Watch() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("创建文件监听器失败: %w", err)
+	}
+	files := make(map[string]bool)
+	dirs := make(map[string]bool)
+	for _, certConfig := range s.configs {
+		for _, file := range []string{certConfig.CertFile, certConfig.KeyFile} {
+			file = filepath.Clean(file)
+			files[file] = true
+			dirs[filepath.Dir(file)] = true
+		}
+	}
+	for dir := range dirs {
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return fmt.Errorf("添加文件监听失败 %s: %w", dir, err)
+		}
+	}
+
+	s.mu.Lock()
+	s.watcher = watcher
+	s.mu.Unlock()
+
+	go func() {
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				name := filepath.Clean(event.Name)
+				// Kubernetes 等通过替换 ..data 符号链接更新挂载的证书
+				if files[name] || strings.HasPrefix(filepath.Base(name), "..") {
+					s.scheduleReload()
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				s.logger.Error("证书文件监听器错误", zap.Error(err))
+			}
+		}
+	}()
+	return nil
+}
+
+// scheduleReload 在 reloadDelay 后重新加载证书，期间的多次变化只触发一次加载
+func (s *CertificateStore) scheduleReload() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.watcher == nil {
+		return
+	}
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	s.timer = time.AfterFunc(reloadDelay, s.reload)
+}
+
+// reload 重新加载证书，失败时保留原证书
+func (s *CertificateStore) reload() {
+	if err := s.load(); err != nil {
+		s.metrics.ObserveReload("failure")
+		s.logger.Error("重新加载证书失败，继续使用原证书", zap.Error(err))
+		return
+	}
+	s.metrics.ObserveReload("success")
+	s.logger.Info("证书重新加载完成", zap.Int("certificate_count", len(s.configs)))
+}
+
+// Close 停止监听证书文件
+func (s *CertificateStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	if s.watcher == nil {
+		return nil
+	}
+	err := s.watcher.Close()
+	s.watcher = nil
+	return err
+}
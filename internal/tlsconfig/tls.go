@@ -0,0 +1,79 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"fmt"
+	"slices"
+
+	"api-gateway/internal/config"
+)
+
+// tlsVersions 支持配置的最低 TLS 版本
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// http2CipherSuites HTTP/2 (RFC 7540 9.2.2) 要求至少启用其中之一
+var http2CipherSuites = []uint16{
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+}
+
+// NewServerConfig 按最低版本与密码套件策略创建 HTTPS 服务器的 tls.Config，证书由 store 按 SNI 选择
+func NewServerConfig(cfg config.TLSConfig, store *CertificateStore) (*tls.Config, error) {
+	minVersion := uint16(tls.VersionTLS12)
+	if cfg.MinVersion != "" {
+		v, ok := tlsVersions[cfg.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("不支持的最低 TLS 版本: %s", cfg.MinVersion)
+		}
+		minVersion = v
+	}
+
+	cipherSuites, err := parseCipherSuites(cfg.CipherSuites)
+	if err != nil {
+		return nil, err
+	}
+	if len(cipherSuites) > 0 && !slices.ContainsFunc(cipherSuites, func(id uint16) bool { return slices.Contains(http2CipherSuites, id) }) {
+		return nil, fmt.Errorf("cipher_suites 需要包含 TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 或 TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256 (HTTP/2 要求)")
+	}
+
+	return &tls.Config{
+		MinVersion:     minVersion,
+		CipherSuites:   cipherSuites,
+		GetCertificate: store.GetCertificate,
+	}, nil
+}
+
+// parseCipherSuites 按 Go 标准名称解析密码套件，不接受已知不安全的套件
+func parseCipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	supported := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		supported[suite.Name] = suite.ID
+	}
+	insecure := make(map[string]bool)
+	for _, suite := range tls.InsecureCipherSuites() {
+		insecure[suite.Name] = true
+	}
+
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := supported[name]
+		switch {
+		case insecure[name]:
+			return nil, fmt.Errorf("密码套件 %s 不安全", name)
+		case !ok:
+			return nil, fmt.Errorf("不支持的密码套件: %s", name)
+		case slices.Contains([]uint16{tls.TLS_AES_128_GCM_SHA256, tls.TLS_AES_256_GCM_SHA384, tls.TLS_CHACHA20_POLY1305_SHA256}, id):
+			return nil, fmt.Errorf("密码套件 %s 属于 TLS 1.3，TLS 1.3 的密码套件不可配置", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -0,0 +1,216 @@
+package transcoder
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"api-gateway/internal/config"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// httpRuleExtension google.api.http 注解在 MethodOptions 中的扩展字段号
+const httpRuleExtension = 72295728
+
+// google.api.HttpRule 的字段号
+const (
+	httpRuleGet                = 2
+	httpRulePut                = 3
+	httpRulePost               = 4
+	httpRuleDelete             = 5
+	httpRulePatch              = 6
+	httpRuleBody               = 7
+	httpRuleCustom             = 8
+	httpRuleAdditionalBindings = 11
+	httpRuleResponseBody       = 12
+	customPatternKind          = 1
+	customPatternPath          = 2
+)
+
+// loadFiles 读取 protoc 生成的描述文件 (FileDescriptorSet)
+func loadFiles(path string) (*protoregistry.Files, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取描述文件失败: %w", err)
+	}
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("解析描述文件失败: %w", err)
+	}
+	files, err := protodesc.NewFiles(&set)
+	if err != nil {
+		return nil, fmt.Errorf("解析描述文件失败 (生成时需使用 --include_imports): %w", err)
+	}
+	return files, nil
+}
+
+// methodRules 从方法的 google.api.http 注解中读取 HTTP 映射 (含 additional_bindings)；
+// 网关不依赖注解的 Go 类型，直接解析 MethodOptions 中的未知字段
+func methodRules(md protoreflect.MethodDescriptor) ([]config.TranscodingBindingConfig, error) {
+	opts, ok := md.Options().(*descriptorpb.MethodOptions)
+	if !ok || opts == nil {
+		return nil, nil
+	}
+	var rules []config.TranscodingBindingConfig
+	b := opts.ProtoReflect().GetUnknown()
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		b = b[n:]
+		if num == httpRuleExtension && typ == protowire.BytesType {
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			parsed, err := parseHTTPRule(v)
+			if err != nil {
+				return nil, err
+			}
+			rules = append(rules, parsed...)
+			b = b[n:]
+			continue
+		}
+		n = protowire.ConsumeFieldValue(num, typ, b)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		b = b[n:]
+	}
+	return rules, nil
+}
+
+// parseHTTPRule 解析 google.api.HttpRule，返回规则本身与 additional_bindings
+func parseHTTPRule(b []byte) ([]config.TranscodingBindingConfig, error) {
+	var rule config.TranscodingBindingConfig
+	var additional []config.TranscodingBindingConfig
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		b = b[n:]
+		if typ != protowire.BytesType {
+			if n = protowire.ConsumeFieldValue(num, typ, b); n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			b = b[n:]
+			continue
+		}
+		v, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		switch num {
+		case httpRuleGet:
+			rule.Method, rule.Path = "GET", string(v)
+		case httpRulePut:
+			rule.Method, rule.Path = "PUT", string(v)
+		case httpRulePost:
+			rule.Method, rule.Path = "POST", string(v)
+		case httpRuleDelete:
+			rule.Method, rule.Path = "DELETE", string(v)
+		case httpRulePatch:
+			rule.Method, rule.Path = "PATCH", string(v)
+		case httpRuleCustom:
+			if rule.Method, rule.Path = parseCustomPattern(v); rule.Method == "" {
+				return nil, fmt.Errorf("custom 映射缺少 kind")
+			}
+		case httpRuleBody:
+			rule.Body = string(v)
+		case httpRuleResponseBody:
+			rule.ResponseBody = string(v)
+		case httpRuleAdditionalBindings:
+			nested, err := parseHTTPRule(v)
+			if err != nil {
+				return nil, err
+			}
+			additional = append(additional, nested...)
+		}
+	}
+	if rule.Path == "" { // 只有 additional_bindings 时忽略规则本身
+		return additional, nil
+	}
+	return append([]config.TranscodingBindingConfig{rule}, additional...), nil
+}
+
+// parseCustomPattern 解析 google.api.CustomHttpPattern
+func parseCustomPattern(b []byte) (method, path string) {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return "", ""
+		}
+		b = b[n:]
+		if typ != protowire.BytesType {
+			if n = protowire.ConsumeFieldValue(num, typ, b); n < 0 {
+				return "", ""
+			}
+			b = b[n:]
+			continue
+		}
+		v, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return "", ""
+		}
+		b = b[n:]
+		switch num {
+		case customPatternKind:
+			method = strings.ToUpper(string(v))
+		case customPatternPath:
+			path = string(v)
+		}
+	}
+	return method, path
+}
+
+// findMethod 按全名查找方法，支持 "pkg.Service.Method" 与 "/pkg.Service/Method" 两种写法
+func findMethod(files *protoregistry.Files, name string) (protoreflect.MethodDescriptor, error) {
+	name = strings.TrimPrefix(name, "/")
+	name = strings.Replace(name, "/", ".", 1)
+	d, err := files.FindDescriptorByName(protoreflect.FullName(name))
+	if err != nil {
+		return nil, fmt.Errorf("描述文件中没有方法 %s", name)
+	}
+	md, ok := d.(protoreflect.MethodDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s 不是 RPC 方法", name)
+	}
+	return md, nil
+}
+
+// findField 按 proto 字段名或 JSON 字段名查找字段
+func findField(md protoreflect.MessageDescriptor, name string) protoreflect.FieldDescriptor {
+	fields := md.Fields()
+	if fd := fields.ByName(protoreflect.Name(name)); fd != nil {
+		return fd
+	}
+	return fields.ByJSONName(name)
+}
+
+// resolveFieldPath 检查以 . 分隔的字段路径：中间字段必须是单值消息字段
+func resolveFieldPath(md protoreflect.MessageDescriptor, fieldPath string) (protoreflect.FieldDescriptor, error) {
+	names := strings.Split(fieldPath, ".")
+	for i, name := range names {
+		fd := findField(md, name)
+		if fd == nil {
+			return nil, fmt.Errorf("%s 中没有字段 %s", md.FullName(), name)
+		}
+		if i == len(names)-1 {
+			return fd, nil
+		}
+		if fd.Message() == nil || fd.IsList() || fd.IsMap() {
+			return nil, fmt.Errorf("字段 %s 不是消息字段", name)
+		}
+		md = fd.Message()
+	}
+	return nil, fmt.Errorf("字段路径为空")
+}
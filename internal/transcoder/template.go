@@ -0,0 +1,214 @@
+package transcoder
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// 路径模板的段类型
+const (
+	segmentLiteral = iota // 字面量
+	segmentSingle         // "*"，匹配一个段
+	segmentMulti          // "**"，匹配剩余的所有段
+)
+
+// pathTemplate google.api.http 路径模板，如 /v1/{name=shelves/*/books/*}:publish
+type pathTemplate struct {
+	segments  []segment
+	variables []variable
+	verb      string
+}
+
+type segment struct {
+	kind    int
+	literal string
+}
+
+// variable 模板变量，对应 segments[start:end] 匹配的部分，end 为 -1 表示直到路径末尾
+type variable struct {
+	fieldPath  string
+	start, end int
+}
+
+// parseTemplate 解析路径模板：
+// Template = "/" Segments [ ":" Verb ]；Segment = "*" | "**" | LITERAL | "{" FieldPath [ "=" Segments ] "}"
+func parseTemplate(template string) (*pathTemplate, error) {
+	if !strings.HasPrefix(template, "/") {
+		return nil, fmt.Errorf("路径模板必须以 / 开头: %s", template)
+	}
+	p := &templateParser{input: template[1:]}
+	t, err := p.parse()
+	if err != nil {
+		return nil, fmt.Errorf("路径模板 %s 无效: %w", template, err)
+	}
+	return t, nil
+}
+
+// templateParser 路径模板解析器
+type templateParser struct {
+	input string
+	pos   int
+	t     pathTemplate
+}
+
+func (p *templateParser) parse() (*pathTemplate, error) {
+	if err := p.parseSegments(false); err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.input) && p.input[p.pos] == ':' {
+		p.t.verb = p.input[p.pos+1:]
+		if p.t.verb == "" || strings.ContainsAny(p.t.verb, "/{}*") {
+			return nil, fmt.Errorf("无效的 verb: %s", p.t.verb)
+		}
+		p.pos = len(p.input)
+	}
+	if p.pos < len(p.input) {
+		return nil, fmt.Errorf("位置 %d 存在多余的字符", p.pos+1)
+	}
+	for i, s := range p.t.segments {
+		if s.kind == segmentMulti && i != len(p.t.segments)-1 {
+			return nil, fmt.Errorf("** 只能是最后一段")
+		}
+	}
+	return &p.t, nil
+}
+
+// parseSegments 解析以 / 分隔的段，inVariable 时遇到 } 结束
+func (p *templateParser) parseSegments(inVariable bool) error {
+	for {
+		if err := p.parseSegment(inVariable); err != nil {
+			return err
+		}
+		if p.pos >= len(p.input) || p.input[p.pos] != '/' {
+			return nil
+		}
+		p.pos++
+	}
+}
+
+func (p *templateParser) parseSegment(inVariable bool) error {
+	rest := p.input[p.pos:]
+	switch {
+	case strings.HasPrefix(rest, "**"):
+		p.t.segments = append(p.t.segments, segment{kind: segmentMulti})
+		p.pos += 2
+	case strings.HasPrefix(rest, "*"):
+		p.t.segments = append(p.t.segments, segment{kind: segmentSingle})
+		p.pos++
+	case strings.HasPrefix(rest, "{"):
+		if inVariable {
+			return fmt.Errorf("变量不能嵌套")
+		}
+		return p.parseVariable()
+	default:
+		end := strings.IndexAny(rest, "/:{}*")
+		if end < 0 {
+			end = len(rest)
+		}
+		if end == 0 {
+			return fmt.Errorf("位置 %d 缺少路径段", p.pos+1)
+		}
+		p.t.segments = append(p.t.segments, segment{kind: segmentLiteral, literal: rest[:end]})
+		p.pos += end
+	}
+	return nil
+}
+
+func (p *templateParser) parseVariable() error {
+	p.pos++ // {
+	rest := p.input[p.pos:]
+	end := strings.IndexAny(rest, "=}")
+	if end <= 0 {
+		return fmt.Errorf("位置 %d 的变量缺少字段名", p.pos)
+	}
+	v := variable{fieldPath: rest[:end], start: len(p.t.segments)}
+	p.pos += end
+	if p.input[p.pos] == '=' {
+		p.pos++
+		if err := p.parseSegments(true); err != nil {
+			return err
+		}
+	} else {
+		p.t.segments = append(p.t.segments, segment{kind: segmentSingle}) // {field} 等同于 {field=*}
+	}
+	if p.pos >= len(p.input) || p.input[p.pos] != '}' {
+		return fmt.Errorf("变量 %s 缺少 }", v.fieldPath)
+	}
+	p.pos++
+	v.end = len(p.t.segments)
+	if p.t.segments[v.end-1].kind == segmentMulti {
+		v.end = -1
+	}
+	p.t.variables = append(p.t.variables, v)
+	return nil
+}
+
+// literalCount 字面量段的数量，用于在多个模板都匹配时优先选择更具体的模板
+func (t *pathTemplate) literalCount() int {
+	n := 0
+	for _, s := range t.segments {
+		if s.kind == segmentLiteral {
+			n++
+		}
+	}
+	if t.verb != "" {
+		n++
+	}
+	return n
+}
+
+// match 匹配转义形式的请求路径，返回变量字段路径到值的映射
+func (t *pathTemplate) match(escapedPath string) (map[string]string, bool) {
+	path := strings.TrimPrefix(escapedPath, "/")
+	if t.verb != "" {
+		var ok bool
+		if path, ok = strings.CutSuffix(path, ":"+t.verb); !ok {
+			return nil, false
+		}
+	}
+	parts := strings.Split(path, "/")
+
+	multi := len(t.segments) > 0 && t.segments[len(t.segments)-1].kind == segmentMulti
+	if multi && len(parts) < len(t.segments)-1 || !multi && len(parts) != len(t.segments) {
+		return nil, false
+	}
+	// 没有 verb 的模板不匹配以 :verb 结尾的路径 ("**" 匹配的剩余路径除外)，否则 :verb 会被并入最后一个变量
+	if t.verb == "" && !multi && strings.Contains(parts[len(parts)-1], ":") {
+		return nil, false
+	}
+	for i, s := range t.segments {
+		switch {
+		case s.kind == segmentLiteral && (i >= len(parts) || parts[i] != s.literal):
+			return nil, false
+		case s.kind == segmentSingle && parts[i] == "": // "*" 与 {field} 不匹配空的路径段
+			return nil, false
+		}
+	}
+
+	vars := make(map[string]string, len(t.variables))
+	for _, v := range t.variables {
+		end := v.end
+		if end < 0 {
+			end = len(parts)
+		}
+		if v.start+1 == end { // 单段变量解码所有转义字符，多段变量保留 %2F
+			value, err := url.PathUnescape(parts[v.start])
+			if err != nil {
+				return nil, false
+			}
+			vars[v.fieldPath] = value
+			continue
+		}
+		values := make([]string, 0, end-v.start)
+		for _, part := range parts[v.start:end] {
+			value, err := url.PathUnescape(strings.NewReplacer("%2F", "%252F", "%2f", "%252f").Replace(part))
+			if err != nil {
+				return nil, false
+			}
+			values = append(values, value)
+		}
+		vars[v.fieldPath] = strings.Join(values, "/")
+	}
+	return vars, true
+}
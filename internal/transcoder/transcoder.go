@@ -0,0 +1,493 @@
+package transcoder
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"api-gateway/internal/config"
+	"api-gateway/pkg/grpcstatus"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// maxMessageSize 请求体与 gRPC 响应消息的大小上限
+const maxMessageSize = 4 << 20
+
+// Transcoder 将 REST/JSON 请求转换为一元 gRPC 请求，并将 gRPC 响应转换回 JSON
+type Transcoder struct {
+	bindings                 []*binding // 按具体程度排序，字面量段多的优先
+	ignoreUnknownQueryParams bool
+	marshal                  protojson.MarshalOptions
+}
+
+// binding 一条 HTTP 方法与路径模板到 RPC 的映射
+type binding struct {
+	rpc           protoreflect.MethodDescriptor
+	grpcPath      string // /package.Service/Method
+	method        string
+	template      *pathTemplate
+	body          string // "*"、字段名或空
+	responseField protoreflect.FieldDescriptor
+}
+
+// Error 转码失败，以 JSON 错误响应返回给客户端
+type Error struct {
+	Status  int
+	Code    grpcstatus.Code
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// New 加载描述文件，按显式绑定与 google.api.http 注解创建 Transcoder
+func New(cfg config.TranscodingConfig) (*Transcoder, error) {
+	if cfg.DescriptorSet == "" {
+		return nil, fmt.Errorf("未配置 descriptor_set")
+	}
+	files, err := loadFiles(cfg.DescriptorSet)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Transcoder{
+		ignoreUnknownQueryParams: cfg.IgnoreUnknownQueryParams,
+		marshal:                  protojson.MarshalOptions{EmitUnpopulated: cfg.EmitUnpopulated, UseProtoNames: cfg.UseProtoNames},
+	}
+
+	explicit := make(map[protoreflect.FullName]bool, len(cfg.Bindings))
+	for i, rule := range cfg.Bindings {
+		md, err := findMethod(files, rule.RPC)
+		if err != nil {
+			return nil, fmt.Errorf("解析第 %d 条绑定失败: %w", i+1, err)
+		}
+		if md.IsStreamingClient() || md.IsStreamingServer() {
+			return nil, fmt.Errorf("解析第 %d 条绑定失败: %s 是流式 RPC，只支持一元 RPC", i+1, md.FullName())
+		}
+		b, err := newBinding(md, rule)
+		if err != nil {
+			return nil, fmt.Errorf("解析第 %d 条绑定失败: %w", i+1, err)
+		}
+		t.bindings = append(t.bindings, b)
+		explicit[md.FullName()] = true
+	}
+
+	services := make(map[string]bool, len(cfg.Services))
+	for _, s := range cfg.Services {
+		services[s] = true
+	}
+	var annotationErr error
+	files.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+		for i := 0; i < fd.Services().Len(); i++ {
+			sd := fd.Services().Get(i)
+			if len(services) > 0 && !services[string(sd.FullName())] {
+				continue
+			}
+			for j := 0; j < sd.Methods().Len(); j++ {
+				md := sd.Methods().Get(j)
+				if explicit[md.FullName()] || md.IsStreamingClient() || md.IsStreamingServer() {
+					continue
+				}
+				rules, err := methodRules(md)
+				if err != nil {
+					annotationErr = fmt.Errorf("解析 %s 的 google.api.http 注解失败: %w", md.FullName(), err)
+					return false
+				}
+				for _, rule := range rules {
+					b, err := newBinding(md, rule)
+					if err != nil {
+						annotationErr = fmt.Errorf("解析 %s 的 google.api.http 注解失败: %w", md.FullName(), err)
+						return false
+					}
+					t.bindings = append(t.bindings, b)
+				}
+			}
+		}
+		return true
+	})
+	if annotationErr != nil {
+		return nil, annotationErr
+	}
+	if len(t.bindings) == 0 {
+		return nil, fmt.Errorf("描述文件 %s 中没有可转码的方法 (需要 google.api.http 注解或显式绑定)", cfg.DescriptorSet)
+	}
+
+	sort.SliceStable(t.bindings, func(i, j int) bool {
+		return t.bindings[i].template.literalCount() > t.bindings[j].template.literalCount()
+	})
+	return t, nil
+}
+
+// newBinding 解析路径模板，并检查模板变量、body 与 response_body 引用的字段
+func newBinding(md protoreflect.MethodDescriptor, rule config.TranscodingBindingConfig) (*binding, error) {
+	if rule.Method == "" || rule.Path == "" {
+		return nil, fmt.Errorf("method 与 path 不能为空")
+	}
+	template, err := parseTemplate(rule.Path)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range template.variables {
+		fd, err := resolveFieldPath(md.Input(), v.fieldPath)
+		if err != nil {
+			return nil, fmt.Errorf("路径变量 %s 无效: %w", v.fieldPath, err)
+		}
+		if fd.IsList() || fd.IsMap() {
+			return nil, fmt.Errorf("路径变量 %s 不能是重复字段", v.fieldPath)
+		}
+	}
+
+	b := &binding{
+		rpc:      md,
+		grpcPath: "/" + string(md.Parent().FullName()) + "/" + string(md.Name()),
+		method:   strings.ToUpper(rule.Method),
+		template: template,
+		body:     rule.Body,
+	}
+	if b.body != "" && b.body != "*" && md.Input().Fields().ByName(protoreflect.Name(b.body)) == nil {
+		return nil, fmt.Errorf("body 字段 %s 不是 %s 的顶层字段", b.body, md.Input().FullName())
+	}
+	if rule.ResponseBody != "" {
+		if b.responseField = md.Output().Fields().ByName(protoreflect.Name(rule.ResponseBody)); b.responseField == nil {
+			return nil, fmt.Errorf("response_body 字段 %s 不是 %s 的顶层字段", rule.ResponseBody, md.Output().FullName())
+		}
+	}
+	return b, nil
+}
+
+// Bindings 返回绑定数量
+func (t *Transcoder) Bindings() int {
+	return len(t.bindings)
+}
+
+// Call 一次转码后的 RPC 调用
+type Call struct {
+	Request *http.Request // 转发到后端的 gRPC 请求
+	RPC     string        // 方法全名
+	binding *binding
+	marshal protojson.MarshalOptions
+}
+
+// Transcode 按请求的方法与路径选择绑定，将路径变量、查询参数与 JSON 请求体转换为 gRPC 请求
+func (t *Transcoder) Transcode(r *http.Request) (*Call, error) {
+	path := r.URL.EscapedPath()
+	var b *binding
+	var vars map[string]string
+	pathMatched := false
+	for _, candidate := range t.bindings {
+		matched, ok := candidate.template.match(path)
+		if !ok {
+			continue
+		}
+		if candidate.method != r.Method {
+			pathMatched = true
+			continue
+		}
+		b, vars = candidate, matched
+		break
+	}
+	if b == nil {
+		if pathMatched {
+			return nil, &Error{Status: http.StatusMethodNotAllowed, Code: grpcstatus.Unimplemented, Message: fmt.Sprintf("路径 %s 不支持 %s 方法", r.URL.Path, r.Method)}
+		}
+		return nil, &Error{Status: http.StatusNotFound, Code: grpcstatus.NotFound, Message: fmt.Sprintf("没有与 %s %s 对应的 RPC", r.Method, r.URL.Path)}
+	}
+
+	msg := dynamicpb.NewMessage(b.rpc.Input())
+	if err := b.decodeBody(r, msg); err != nil {
+		return nil, err
+	}
+	if b.body != "*" { // body 为 * 时请求消息全部来自请求体
+		for name, values := range r.URL.Query() {
+			if err := setField(msg, name, values); err != nil {
+				if t.ignoreUnknownQueryParams && errors.Is(err, errUnknownField) {
+					continue
+				}
+				return nil, invalidArgument("查询参数 %s 无效: %v", name, err)
+			}
+		}
+	}
+	for fieldPath, value := range vars { // 路径变量优先于查询参数
+		if err := setField(msg, fieldPath, []string{value}); err != nil {
+			return nil, invalidArgument("路径变量 %s 无效: %v", fieldPath, err)
+		}
+	}
+
+	payload, err := proto.Marshal(msg)
+	if err != nil {
+		return nil, &Error{Status: http.StatusInternalServerError, Code: grpcstatus.Internal, Message: "序列化请求消息失败: " + err.Error()}
+	}
+	frame := make([]byte, 5+len(payload)) // 未压缩的 gRPC 消息帧
+	binary.BigEndian.PutUint32(frame[1:5], uint32(len(payload)))
+	copy(frame[5:], payload)
+
+	outreq := r.Clone(r.Context())
+	outreq.Method = http.MethodPost
+	outreq.URL.Path, outreq.URL.RawPath, outreq.URL.RawQuery = b.grpcPath, "", ""
+	outreq.Body = io.NopCloser(bytes.NewReader(frame))
+	outreq.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(frame)), nil }
+	outreq.ContentLength = int64(len(frame))
+	outreq.Header.Del("Content-Length")
+	outreq.Header.Del("Accept-Encoding")
+	outreq.Header.Del("Grpc-Accept-Encoding") // 后端不压缩响应消息
+	outreq.Header.Set("Content-Type", "application/grpc")
+	outreq.Header.Set("Te", "trailers")
+	return &Call{Request: outreq, RPC: string(b.rpc.FullName()), binding: b, marshal: t.marshal}, nil
+}
+
+// decodeBody 将 JSON 请求体解析到请求消息或 body 指定的字段
+func (b *binding) decodeBody(r *http.Request, msg *dynamicpb.Message) error {
+	if b.body == "" || r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxMessageSize+1))
+	if err != nil {
+		return invalidArgument("读取请求体失败: %v", err)
+	}
+	if len(data) > maxMessageSize {
+		return &Error{Status: http.StatusRequestEntityTooLarge, Code: grpcstatus.ResourceExhausted, Message: "请求体过大"}
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	if b.body != "*" { // 请求体是 body 字段的值，包装为消息的 JSON 再解析
+		data = append(append([]byte(`{"`+b.body+`":`), data...), '}')
+	}
+	if err := protojson.Unmarshal(data, msg); err != nil {
+		return invalidArgument("解析请求体失败: %v", err)
+	}
+	return nil
+}
+
+// Response 将后端的 gRPC 响应转换为 HTTP 状态码与 JSON 响应体；
+// grpc-status 可能位于响应头 (Trailers-Only) 或由 ReverseProxy 写入的 trailer 中
+func (c *Call) Response(status int, header http.Header, body []byte) (int, []byte) {
+	if status != http.StatusOK { // 网关或非 gRPC 后端返回的 HTTP 错误
+		code := grpcstatus.FromHTTPStatus(status)
+		return status, errorJSON(code, strings.TrimSpace(string(body)))
+	}
+	value := trailerValue(header, "Grpc-Status")
+	if value == "" {
+		return c.fail(grpcstatus.Internal, "后端响应缺少 grpc-status")
+	}
+	code, err := grpcstatus.Parse(value)
+	if err != nil {
+		return c.fail(grpcstatus.Internal, err.Error())
+	}
+	if code != grpcstatus.OK {
+		return c.fail(code, grpcstatus.DecodeMessage(trailerValue(header, "Grpc-Message")))
+	}
+
+	if len(body) < 5 {
+		return c.fail(grpcstatus.Internal, "后端响应缺少消息")
+	}
+	if body[0] != 0 {
+		return c.fail(grpcstatus.Internal, "不支持压缩的响应消息")
+	}
+	length := binary.BigEndian.Uint32(body[1:5])
+	if length > maxMessageSize || int(length) > len(body)-5 {
+		return c.fail(grpcstatus.Internal, "后端响应消息不完整")
+	}
+	msg := dynamicpb.NewMessage(c.binding.rpc.Output())
+	if err := proto.Unmarshal(body[5:5+length], msg); err != nil {
+		return c.fail(grpcstatus.Internal, "解析响应消息失败: "+err.Error())
+	}
+
+	data, err := c.marshal.Marshal(msg)
+	if err != nil {
+		return c.fail(grpcstatus.Internal, "序列化响应失败: "+err.Error())
+	}
+	if fd := c.binding.responseField; fd != nil {
+		data = extractField(data, fd, c.marshal.UseProtoNames)
+	}
+	return http.StatusOK, data
+}
+
+func (c *Call) fail(code grpcstatus.Code, message string) (int, []byte) {
+	return grpcstatus.ToHTTPStatus(code), errorJSON(code, message)
+}
+
+// extractField 从响应消息的 JSON 中取出 response_body 字段，字段未填充时返回 null
+func extractField(data []byte, fd protoreflect.FieldDescriptor, useProtoNames bool) []byte {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return []byte("null")
+	}
+	name := fd.JSONName()
+	if useProtoNames {
+		name = string(fd.Name())
+	}
+	if value, ok := fields[name]; ok {
+		return value
+	}
+	return []byte("null")
+}
+
+// trailerValue 读取 gRPC 状态：ReverseProxy 将未预先声明的 trailer 以 http.TrailerPrefix 为前缀写入响应头
+func trailerValue(header http.Header, key string) string {
+	if v := header.Get(key); v != "" {
+		return v
+	}
+	if v := header[http.TrailerPrefix+key]; len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// ErrorJSON 返回转码错误的 HTTP 状态码与 JSON 响应体
+func ErrorJSON(err error) (int, []byte) {
+	var e *Error
+	if !errors.As(err, &e) {
+		return http.StatusInternalServerError, errorJSON(grpcstatus.Internal, err.Error())
+	}
+	return e.Status, errorJSON(e.Code, e.Message)
+}
+
+// errorJSON 错误响应体，格式与 google.rpc.Status 的 JSON 形式一致
+func errorJSON(code grpcstatus.Code, message string) []byte {
+	data, _ := json.Marshal(struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}{int(code), message})
+	return data
+}
+
+func invalidArgument(format string, args ...any) *Error {
+	return &Error{Status: http.StatusBadRequest, Code: grpcstatus.InvalidArgument, Message: fmt.Sprintf(format, args...)}
+}
+
+// errUnknownField 查询参数对应的字段不存在
+var errUnknownField = errors.New("请求消息中没有对应的字段")
+
+// setField 按字段路径设置请求消息的字段，字段名可以是 proto 字段名或 JSON 字段名
+func setField(msg protoreflect.Message, fieldPath string, values []string) error {
+	names := strings.Split(fieldPath, ".")
+	for i, name := range names {
+		fd := findField(msg.Descriptor(), name)
+		if fd == nil {
+			return errUnknownField
+		}
+		if i < len(names)-1 {
+			if fd.Message() == nil || fd.IsList() || fd.IsMap() {
+				return fmt.Errorf("字段 %s 不是消息字段", name)
+			}
+			msg = msg.Mutable(fd).Message()
+			continue
+		}
+
+		switch {
+		case fd.IsMap():
+			return fmt.Errorf("不支持设置 map 字段 %s", name)
+		case fd.IsList():
+			list := msg.Mutable(fd).List()
+			for _, s := range values {
+				v, err := parseValue(fd, list.NewElement, s)
+				if err != nil {
+					return err
+				}
+				list.Append(v)
+			}
+		default:
+			if len(values) != 1 {
+				return fmt.Errorf("字段 %s 不是重复字段，只能有一个值", name)
+			}
+			v, err := parseValue(fd, func() protoreflect.Value { return msg.NewField(fd) }, values[0])
+			if err != nil {
+				return err
+			}
+			msg.Set(fd, v)
+		}
+	}
+	return nil
+}
+
+// parseValue 将字符串解析为字段的值；消息字段支持包装类型与 Timestamp、Duration 等以 JSON 字符串表示的类型
+func parseValue(fd protoreflect.FieldDescriptor, newValue func() protoreflect.Value, s string) (protoreflect.Value, error) {
+	if fd.Message() == nil {
+		return parseScalar(fd, s)
+	}
+	v := newValue()
+	m := v.Message()
+	if isWrapper(fd.Message()) {
+		inner := fd.Message().Fields().ByName("value")
+		iv, err := parseScalar(inner, s)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		m.Set(inner, iv)
+		return v, nil
+	}
+	quoted, _ := json.Marshal(s)
+	if err := protojson.Unmarshal(quoted, m.Interface()); err != nil {
+		return protoreflect.Value{}, fmt.Errorf("无法将 %q 解析为 %s", s, fd.Message().FullName())
+	}
+	return v, nil
+}
+
+// isWrapper 是否为 google.protobuf.Int32Value 等包装类型
+func isWrapper(md protoreflect.MessageDescriptor) bool {
+	return md.ParentFile().Package() == "google.protobuf" && strings.HasSuffix(string(md.Name()), "Value") &&
+		md.Fields().Len() == 1 && md.Fields().ByName("value") != nil
+}
+
+// parseScalar 按字段类型解析标量值
+func parseScalar(fd protoreflect.FieldDescriptor, s string) (protoreflect.Value, error) {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(s), nil
+	case protoreflect.BoolKind:
+		b, err := strconv.ParseBool(s)
+		return protoreflect.ValueOfBool(b), wrapParseError(fd, s, err)
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		n, err := strconv.ParseInt(s, 10, 32)
+		return protoreflect.ValueOfInt32(int32(n)), wrapParseError(fd, s, err)
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		n, err := strconv.ParseInt(s, 10, 64)
+		return protoreflect.ValueOfInt64(n), wrapParseError(fd, s, err)
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		n, err := strconv.ParseUint(s, 10, 32)
+		return protoreflect.ValueOfUint32(uint32(n)), wrapParseError(fd, s, err)
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		n, err := strconv.ParseUint(s, 10, 64)
+		return protoreflect.ValueOfUint64(n), wrapParseError(fd, s, err)
+	case protoreflect.FloatKind:
+		f, err := strconv.ParseFloat(s, 32)
+		return protoreflect.ValueOfFloat32(float32(f)), wrapParseError(fd, s, err)
+	case protoreflect.DoubleKind:
+		f, err := strconv.ParseFloat(s, 64)
+		return protoreflect.ValueOfFloat64(f), wrapParseError(fd, s, err)
+	case protoreflect.BytesKind:
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			b, err = base64.URLEncoding.DecodeString(s)
+		}
+		return protoreflect.ValueOfBytes(b), wrapParseError(fd, s, err)
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByName(protoreflect.Name(s)); ev != nil {
+			return protoreflect.ValueOfEnum(ev.Number()), nil
+		}
+		n, err := strconv.ParseInt(s, 10, 32)
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(n)), wrapParseError(fd, s, err)
+	default:
+		return protoreflect.Value{}, fmt.Errorf("不支持的字段类型 %s", fd.Kind())
+	}
+}
+
+func wrapParseError(fd protoreflect.FieldDescriptor, s string, err error) error {
+	if err != nil {
+		return fmt.Errorf("无法将 %q 解析为 %s 类型的字段 %s", s, fd.Kind(), fd.Name())
+	}
+	return nil
+}
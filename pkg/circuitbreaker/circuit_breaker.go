@@ -0,0 +1,154 @@
+package circuitbreaker
+
+import (
+	"sync"
+	"time"
+)
+
+// State 熔断器状态
+type State int
+
+const (
+	StateClosed   State = iota // 关闭：正常放行
+	StateOpen                  // 打开：快速失败
+	StateHalfOpen              // 半开：放行少量探测请求
+)
+
+// String 返回状态名称
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half_open"
+	default:
+		return "unknown"
+	}
+}
+
+// Settings 熔断器参数
+type Settings struct {
+	FailureThreshold    int                  // 关闭状态下连续失败多少次后打开
+	OpenTimeout         time.Duration        // 打开多久后进入半开状态
+	HalfOpenMaxRequests int                  // 半开状态允许同时进行的探测请求数
+	SuccessThreshold    int                  // 半开状态连续成功多少次后关闭
+	OnStateChange       func(from, to State) // 状态变化回调 (可选)，在持有锁时调用，不应阻塞
+}
+
+// CircuitBreaker 熔断器
+type CircuitBreaker struct {
+	settings         Settings
+	mu               sync.Mutex
+	state            State
+	failures         int       // 关闭状态下的连续失败次数
+	successes        int       // 半开状态下的连续成功次数
+	halfOpenInFlight int       // 半开状态下进行中的探测请求数
+	openedAt         time.Time // 最近一次打开的时间
+}
+
+// NewCircuitBreaker 创建熔断器
+func NewCircuitBreaker(settings Settings) *CircuitBreaker {
+	if settings.FailureThreshold <= 0 {
+		settings.FailureThreshold = 5 // 默认值
+	}
+	if settings.OpenTimeout <= 0 {
+		settings.OpenTimeout = 30 * time.Second // 默认值
+	}
+	if settings.HalfOpenMaxRequests <= 0 {
+		settings.HalfOpenMaxRequests = 1 // 默认值
+	}
+	if settings.SuccessThreshold <= 0 {
+		settings.SuccessThreshold = settings.HalfOpenMaxRequests
+	}
+	return &CircuitBreaker{settings: settings}
+}
+
+// State 返回当前状态
+func (cb *CircuitBreaker) State() State {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.checkOpenTimeout()
+	return cb.state
+}
+
+// Allow 判断是否放行请求，放行后必须调用 Record 或 Cancel
+func (cb *CircuitBreaker) Allow() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.checkOpenTimeout()
+
+	switch cb.state {
+	case StateOpen:
+		return false
+	case StateHalfOpen:
+		if cb.halfOpenInFlight >= cb.settings.HalfOpenMaxRequests {
+			return false
+		}
+		cb.halfOpenInFlight++
+	}
+	return true
+}
+
+// Record 记录已放行请求的结果
+func (cb *CircuitBreaker) Record(success bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case StateClosed:
+		if success {
+			cb.failures = 0
+			return
+		}
+		cb.failures++
+		if cb.failures >= cb.settings.FailureThreshold {
+			cb.setState(StateOpen)
+		}
+	case StateHalfOpen:
+		cb.halfOpenInFlight = max(cb.halfOpenInFlight-1, 0)
+		if !success {
+			cb.setState(StateOpen)
+			return
+		}
+		cb.successes++
+		if cb.successes >= cb.settings.SuccessThreshold {
+			cb.setState(StateClosed)
+		}
+	}
+}
+
+// Cancel 放行的请求未产生有效结果 (例如客户端取消) 时调用，只释放半开探测名额
+func (cb *CircuitBreaker) Cancel() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if cb.state == StateHalfOpen {
+		cb.halfOpenInFlight = max(cb.halfOpenInFlight-1, 0)
+	}
+}
+
+// checkOpenTimeout 打开超时后切换为半开状态，调用方需持有锁
+func (cb *CircuitBreaker) checkOpenTimeout() {
+	if cb.state == StateOpen && time.Since(cb.openedAt) >= cb.settings.OpenTimeout {
+		cb.setState(StateHalfOpen)
+	}
+}
+
+// setState 切换状态并重置计数，调用方需持有锁
+func (cb *CircuitBreaker) setState(to State) {
+	from := cb.state
+	if from == to {
+		return
+	}
+	cb.state = to
+	cb.failures = 0
+	cb.successes = 0
+	cb.halfOpenInFlight = 0
+	if to == StateOpen {
+		cb.openedAt = time.Now()
+	}
+	if cb.settings.OnStateChange != nil {
+		cb.settings.OnStateChange(from, to)
+	}
+}
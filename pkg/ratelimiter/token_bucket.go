@@ -1,11 +1,13 @@
 package ratelimiter
 
 import (
+	"sync"
 	"time"
 )
 
-// TokenBucketLimiter 令牌桶限流器
+// TokenBucketLimiter 令牌桶限流器，可并发使用
 type TokenBucketLimiter struct {
+	mu                sync.Mutex
 	capacity          int
 	tokens            int
 	refillRate        int
@@ -43,6 +45,8 @@ func NewTokenBucketLimiter(requests int, interval time.Duration) *TokenBucketLim
 
 // Allow 尝试获取令牌，成功返回 true，否则返回 false
 func (limiter *TokenBucketLimiter) Allow() bool {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
 	limiter.refill()
 
 	if limiter.tokens > 0 {